@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scythe504/tiny-rl/internal"
+	"github.com/scythe504/tiny-rl/internal/database"
+	"github.com/scythe504/tiny-rl/internal/export"
+	"github.com/scythe504/tiny-rl/internal/referrers"
+)
+
+const usage = `Usage: admin <command> [flags]
+
+Commands:
+  accounts create -name <name>             create an account and print its first api key
+  keys create -account <id>                print a new api key for an account
+  links assign -code <code> -account <id>  give an existing link to an account,
+                [-domain <hostname>]       on a branded domain
+  domains add -host <hostname>             serve short links from a branded domain,
+              [-account <id>]              only usable by one account
+  clicks classify-referrers                normalize the referrers of clicks recorded
+                [-rules <file>]            before referrer categories existed
+  clicks export -account <id>              write the raw clicks of an account as csv,
+                [-code <code>] [-domain <hostname>]
+                [-format csv|ndjson|parquet] [-from <time>] [-to <time>]
+                [-include-bots true|false|only] [-drop <fields>] [-o <file>]
+`
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	db := database.New()
+	defer db.Close()
+
+	command, args := os.Args[1]+" "+os.Args[2], os.Args[3:]
+
+	switch command {
+	case "accounts create":
+		createAccount(db, args)
+	case "keys create":
+		createKey(db, args)
+	case "links assign":
+		assignLink(db, args)
+	case "domains add":
+		addDomain(db, args)
+	case "clicks classify-referrers":
+		classifyReferrers(db, args)
+	case "clicks export":
+		exportClicks(db, args)
+	default:
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+}
+
+func createAccount(db database.Service, args []string) {
+	fs := flag.NewFlagSet("accounts create", flag.ExitOnError)
+	name := fs.String("name", "", "name of the account")
+	fs.Parse(args)
+
+	if *name == "" {
+		log.Fatal("❌ -name is required")
+	}
+
+	account, err := db.CreateAccount(*name)
+	if err != nil {
+		log.Fatal("❌ Failed to create account:", err)
+	}
+	log.Printf("✅ Account #%d (%s) created\n", account.Id, account.Name)
+
+	printNewKey(db, account.Id)
+}
+
+func createKey(db database.Service, args []string) {
+	fs := flag.NewFlagSet("keys create", flag.ExitOnError)
+	accountId := fs.Int("account", 0, "id of the account")
+	fs.Parse(args)
+
+	if *accountId == 0 {
+		log.Fatal("❌ -account is required")
+	}
+
+	printNewKey(db, *accountId)
+}
+
+func assignLink(db database.Service, args []string) {
+	fs := flag.NewFlagSet("links assign", flag.ExitOnError)
+	shortCode := fs.String("code", "", "short code of the link")
+	accountId := fs.Int("account", 0, "id of the account")
+	domain := fs.String("domain", "", "hostname of the branded domain, empty for the default domain")
+	fs.Parse(args)
+
+	if *shortCode == "" || *accountId == 0 {
+		log.Fatal("❌ -code and -account are required")
+	}
+
+	assigned, err := db.AssignLinkOwner(*domain, *shortCode, *accountId)
+	if err != nil {
+		log.Fatal("❌ Failed to assign link:", err)
+	}
+	if !assigned {
+		log.Fatalf("❌ Link %s does not exist\n", *shortCode)
+	}
+
+	log.Printf("✅ Link %s now belongs to account #%d\n", *shortCode, *accountId)
+}
+
+func addDomain(db database.Service, args []string) {
+	fs := flag.NewFlagSet("domains add", flag.ExitOnError)
+	hostname := fs.String("host", "", "hostname of the domain, e.g. go.example.com")
+	accountId := fs.Int("account", 0, "id of the only account allowed to use the domain")
+	fs.Parse(args)
+
+	*hostname = strings.ToLower(*hostname)
+	if !internal.ValidHostname(*hostname) {
+		log.Fatal("❌ -host must be a hostname like go.example.com")
+	}
+
+	var owner *int
+	if *accountId != 0 {
+		owner = accountId
+	}
+
+	domain, err := db.CreateDomain(*hostname, owner)
+	if err != nil {
+		log.Fatal("❌ Failed to add domain:", err)
+	}
+
+	log.Printf("✅ Domain #%d (%s) added, point its DNS at this server\n", domain.Id, domain.Hostname)
+}
+
+// classifyBatchSize is the number of distinct referrers handled per query
+const classifyBatchSize = 500
+
+func classifyReferrers(db database.Service, args []string) {
+	fs := flag.NewFlagSet("clicks classify-referrers", flag.ExitOnError)
+	rulesFile := fs.String("rules", os.Getenv("REFERRER_RULES_FILE"), "referrer rules file, the built in rules when empty")
+	fs.Parse(args)
+
+	rules := referrers.DefaultRules()
+	if *rulesFile != "" {
+		var err error
+		if rules, err = referrers.LoadRules(*rulesFile); err != nil {
+			log.Fatal("❌ Failed to load rules:", err)
+		}
+	}
+
+	var total int64
+	for {
+		raws, err := db.ListUnclassifiedReferrers(classifyBatchSize)
+		if err != nil {
+			log.Fatal("❌ Failed to list referrers:", err)
+		}
+		if len(raws) == 0 {
+			break
+		}
+
+		// Every referrer listed gets a domain, so the next batch moves on
+		for _, raw := range raws {
+			ref := rules.Classify(raw)
+			updated, err := db.ClassifyReferrer(raw, ref.Referrer, ref.Domain, string(ref.Category))
+			if err != nil {
+				log.Fatal("❌ Failed to classify referrer:", err)
+			}
+			total += updated
+		}
+	}
+
+	log.Printf("✅ Classified the referrers of %d clicks\n", total)
+}
+
+func exportClicks(db database.Service, args []string) {
+	fs := flag.NewFlagSet("clicks export", flag.ExitOnError)
+	accountId := fs.Int("account", 0, "id of the account")
+	shortCode := fs.String("code", "", "short code of a single link to export")
+	domain := fs.String("domain", "", "hostname of the branded domain of -code")
+	rawFormat := fs.String("format", "csv", "csv, ndjson or parquet")
+	from := fs.String("from", "", "first click, an RFC 3339 time or a date in UTC")
+	to := fs.String("to", "", "end of the range, an RFC 3339 time or a date in UTC which is included")
+	includeBots := fs.String("include-bots", "false", "true, false or only")
+	drop := fs.String("drop", "", "comma separated fields to leave out besides EXPORT_DROP_FIELDS")
+	output := fs.String("o", "", "output file, stdout when empty")
+	fs.Parse(args)
+
+	if *accountId == 0 {
+		log.Fatal("❌ -account is required")
+	}
+
+	format, err := export.ParseFormat(*rawFormat)
+	if err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	privacy, err := export.PrivacyFromEnv()
+	if err != nil {
+		log.Fatal("❌ Invalid export privacy settings:", err)
+	}
+	if *drop != "" {
+		if privacy, err = privacy.Drop(strings.Split(*drop, ",")); err != nil {
+			log.Fatal("❌ Invalid -drop:", err)
+		}
+	}
+
+	rng := database.ClickRange{
+		From: parseExportTime("-from", *from, false),
+		To:   parseExportTime("-to", *to, true),
+	}
+	switch *includeBots {
+	case "true":
+		rng.Bots = database.IncludeBots
+	case "only":
+		rng.Bots = database.OnlyBots
+	case "false":
+	default:
+		log.Fatal("❌ -include-bots must be true, false or only")
+	}
+
+	var linkId int
+	if *shortCode != "" {
+		link, err := db.GetLink(*domain, *shortCode)
+		if err != nil {
+			log.Fatalf("❌ Link %s could not be loaded: %v\n", *shortCode, err)
+		}
+		if link.OwnerId == nil || *link.OwnerId != *accountId {
+			log.Fatalf("❌ Link %s does not belong to account #%d\n", *shortCode, *accountId)
+		}
+		linkId = link.Id
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			log.Fatal("❌ Failed to create output file:", err)
+		}
+		defer out.Close()
+	}
+
+	writer, err := export.NewWriter(out, format, privacy)
+	if err != nil {
+		log.Fatal("❌ Failed to start export:", err)
+	}
+
+	count := 0
+	err = db.ExportClicks(context.Background(), *accountId, linkId, rng, func(click *database.ExportedClick) error {
+		count++
+		return writer.Write(click)
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		log.Fatal("❌ Export failed:", err)
+	}
+
+	log.Printf("✅ Exported %d clicks\n", count)
+}
+
+// parseExportTime reads an RFC 3339 time or a date in UTC, endOfDay moves
+// dates to the start of the following day.
+func parseExportTime(flagName string, raw string, endOfDay bool) *time.Time {
+	if raw == "" {
+		return nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t
+	}
+
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		log.Fatalf("❌ %s must be an RFC 3339 time or a date like 2006-01-02\n", flagName)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return &t
+}
+
+// printNewKey creates an api key and prints it, the key cannot be recovered
+// afterwards since only its hash is stored.
+func printNewKey(db database.Service, accountId int) {
+	key, prefix, err := internal.GenerateAPIKey()
+	if err != nil {
+		log.Fatal("❌ Failed to generate api key:", err)
+	}
+
+	if _, err := db.CreateApiKey(accountId, prefix, internal.HashAPIKey(key)); err != nil {
+		log.Fatal("❌ Failed to store api key:", err)
+	}
+
+	log.Println("🔑 API key (shown only once):")
+	fmt.Println(key)
+}
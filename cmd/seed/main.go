@@ -15,6 +15,7 @@ import (
 	"github.com/scythe504/tiny-rl/internal"
 	"github.com/scythe504/tiny-rl/internal/database"
 	"github.com/scythe504/tiny-rl/internal/geodatabase"
+	"github.com/scythe504/tiny-rl/internal/referrers"
 )
 
 const BATCH_SIZE = 500 // Adjust based on your needs (500-1000 works well)
@@ -88,7 +89,7 @@ var (
 		"https://facebook.com",
 		"https://linkedin.com",
 		"https://stackoverflow.com",
-		"direct",
+		"", // direct
 	}
 )
 
@@ -117,25 +118,58 @@ func main() {
 	log.Println("✅ Transaction Started")
 	defer tx.Rollback()
 
+	// Insert demo account owning the demo link
+	var accountId int
+	err = tx.QueryRow(`INSERT INTO accounts (name) VALUES ('demo') RETURNING id`).Scan(&accountId)
+	if err != nil {
+		log.Fatal("❌ Failed to insert account:", err)
+	}
+
+	apiKey, prefix, err := internal.GenerateAPIKey()
+	if err != nil {
+		log.Fatal("❌ Failed to generate api key:", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO api_keys (account_id, prefix, key_hash) VALUES ($1, $2, $3)`,
+		accountId, prefix, internal.HashAPIKey(apiKey))
+	if err != nil {
+		log.Fatal("❌ Failed to insert api key:", err)
+	}
+	log.Printf("✅ Demo Account #%d Inserted\n", accountId)
+
 	// Insert link_map
 	link_map := database.LinkMap{
 		ShortCode: "demo-code",
 		Url:       "https://github.com/scythe504/tiny-rl",
+		OwnerId:   &accountId,
 	}
 
-	stmt := `INSERT INTO link_map (short_code, url) VALUES ($1, $2) 
-         ON CONFLICT (short_code) DO NOTHING`
+	// The demo link lives on the default domain
+	stmt := `INSERT INTO link_map (short_code, url, owner_id, domain_id) 
+         VALUES ($1, $2, $3, (SELECT id FROM domains WHERE hostname IS NULL)) 
+         ON CONFLICT (domain_id, short_code) DO UPDATE SET owner_id = EXCLUDED.owner_id
+         RETURNING id`
 
-	_, err = tx.Exec(stmt, link_map.ShortCode, link_map.Url)
+	err = tx.QueryRow(stmt, link_map.ShortCode, link_map.Url, link_map.OwnerId).Scan(&link_map.Id)
 	if err != nil {
 		log.Fatal("❌ Failed to insert link_map:", err)
 	}
+
+	stmt = `INSERT INTO link_revisions (link_id, revision, url) VALUES ($1, 1, $2)
+         ON CONFLICT (link_id, revision) DO NOTHING`
+
+	_, err = tx.Exec(stmt, link_map.Id, link_map.Url)
+	if err != nil {
+		log.Fatal("❌ Failed to insert link_revisions:", err)
+	}
 	log.Println("✅ Link Map Inserted/Updated")
 
 	// Initialize GeoDb
 	geodb := geodatabase.New()
 	log.Println("✅ GeoDb Connection Opened")
 
+	referrerRules := referrers.DefaultRules()
+
 	// Time Range
 	endDate := time.Now()
 	startDate := endDate.AddDate(-1, 0, 0)
@@ -177,16 +211,21 @@ func main() {
 			secOffset := RandomInt(0, secondsInDay)
 			timestamp := dayStart.Add(time.Duration(secOffset) * time.Second)
 			hashedIp := internal.HashIPWithDate(ipAddr, HASH_SALT, timestamp)
+			ref := referrerRules.Classify(RandomChoice(Referrers))
 
 			click := database.Clicks{
-				ShortCode:      "demo-code",
-				Browser:        RandomChoice(Browsers),
-				UserAgent:      RandomChoice(UserAgents),
-				IpAddr:         hashedIp,
-				Referrer:       RandomChoice(Referrers),
-				Country:        countryName,
-				CountryISOCode: countryIsoCode,
-				ClickedAt:      timestamp,
+				LinkId:           link_map.Id,
+				Browser:          RandomChoice(Browsers),
+				UserAgent:        RandomChoice(UserAgents),
+				IpAddr:           hashedIp,
+				Referrer:         ref.Referrer,
+				RawReferrer:      ref.Raw,
+				ReferrerDomain:   ref.Domain,
+				ReferrerCategory: string(ref.Category),
+				Country:          countryName,
+				CountryISOCode:   countryIsoCode,
+				ClickedAt:        timestamp,
+				Revision:         1,
 			}
 
 			batch = append(batch, click)
@@ -239,6 +278,7 @@ func main() {
 	log.Printf("📅 Days Processed: %d\n", dayCount)
 	log.Printf("⏱️  Total Time: %s\n", endTime.Round(time.Second))
 	log.Printf("⚡ Average Speed: %.0f records/second\n", float64(totalRecords)/endTime.Seconds())
+	log.Printf("🔑 Demo API Key: %s\n", apiKey)
 	
 	conn.Close()
 	geodb.Close()
@@ -253,33 +293,42 @@ func executeBatchInsert(tx *sql.Tx, clicks []database.Clicks) (int, error) {
 
 	// Build the query with placeholders
 	valueStrings := make([]string, 0, len(clicks))
-	valueArgs := make([]interface{}, 0, len(clicks)*8)
+	valueArgs := make([]interface{}, 0, len(clicks)*12)
 
 	for i, click := range clicks {
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			i*8+1, i*8+2, i*8+3, i*8+4, i*8+5, i*8+6, i*8+7, i*8+8))
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			i*12+1, i*12+2, i*12+3, i*12+4, i*12+5, i*12+6, i*12+7, i*12+8, i*12+9, i*12+10, i*12+11, i*12+12))
 		
 		valueArgs = append(valueArgs,
-			click.ShortCode,
+			click.LinkId,
 			click.Browser,
-			click.ClickedAt,
+			// clicked_at has no time zone and always holds UTC
+			click.ClickedAt.UTC(),
 			click.UserAgent,
 			click.IpAddr,
 			click.Referrer,
 			click.Country,
 			click.CountryISOCode,
+			click.Revision,
+			click.RawReferrer,
+			click.ReferrerDomain,
+			click.ReferrerCategory,
 		)
 	}
 
 	stmt := fmt.Sprintf(`INSERT INTO clicks (
-		short_code,
+		link_id,
 		browser, 
 		clicked_at, 
 		user_agent, 
 		ip_addr, 
 		referrer, 
 		country, 
-		country_iso_code
+		country_iso_code,
+		revision,
+		raw_referrer,
+		referrer_domain,
+		referrer_category
 	) VALUES %s`, strings.Join(valueStrings, ","))
 
 	result, err := tx.Exec(stmt, valueArgs...)
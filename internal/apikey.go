@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const apiKeyPrefix = "trl_"
+
+// GenerateAPIKey returns a new random API key together with the short prefix
+// that is stored in clear text so users can tell their keys apart.
+func GenerateAPIKey() (key string, prefix string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	key = apiKeyPrefix + hex.EncodeToString(secret)
+
+	return key, key[:len(apiKeyPrefix)+8], nil
+}
+
+// HashAPIKey hashes an API key for storage and lookup. Keys carry 256 bits of
+// randomness so a plain SHA-256 is enough, unlike user chosen passwords.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(hash[:])
+}
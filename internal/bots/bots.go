@@ -0,0 +1,76 @@
+// Package bots tells clicks by people apart from crawlers, link preview
+// fetchers and monitoring.
+package bots
+
+import (
+	"net"
+	"strings"
+
+	"github.com/mileusna/useragent"
+)
+
+// DefaultUserAgents are user agent fragments of link preview fetchers, uptime
+// checkers and HTTP libraries that useragent does not flag as bots.
+var DefaultUserAgents = []string{
+	"slackbot", "slack-imgproxy", "twitterbot", "facebookexternalhit",
+	"linkedinbot", "discordbot", "telegrambot", "whatsapp", "skypeuripreview",
+	"embedly", "redditbot", "pinterestbot", "applebot", "bingpreview",
+	"uptimerobot", "pingdom", "statuscake", "site24x7", "betteruptime",
+	"headlesschrome", "curl/", "wget/", "python-requests", "go-http-client",
+	"okhttp", "axios/", "node-fetch",
+}
+
+// ASNLookup returns the autonomous system number of an IP.
+type ASNLookup func(ip net.IP) (uint, error)
+
+// Classifier decides whether a click came from a bot. It is safe for
+// concurrent use.
+type Classifier struct {
+	userAgents []string
+	asns       map[uint]bool
+	lookupASN  ASNLookup
+}
+
+// NewClassifier flags user agents containing any of userAgents (case
+// insensitive) and, when lookupASN is set, IPs of the given ASNs, e.g. those
+// of cloud providers.
+func NewClassifier(userAgents []string, asns []uint, lookupASN ASNLookup) *Classifier {
+	c := &Classifier{
+		asns:      make(map[uint]bool, len(asns)),
+		lookupASN: lookupASN,
+	}
+
+	for _, fragment := range userAgents {
+		if fragment = strings.ToLower(strings.TrimSpace(fragment)); fragment != "" {
+			c.userAgents = append(c.userAgents, fragment)
+		}
+	}
+	for _, asn := range asns {
+		c.asns[asn] = true
+	}
+
+	return c
+}
+
+// IsBot classifies a visitor by its user agent and IP. Requests without a
+// user agent are never sent by browsers and count as bots.
+func (c *Classifier) IsBot(userAgent string, ua useragent.UserAgent, ip net.IP) bool {
+	if strings.TrimSpace(userAgent) == "" || ua.Bot {
+		return true
+	}
+
+	lower := strings.ToLower(userAgent)
+	for _, fragment := range c.userAgents {
+		if strings.Contains(lower, fragment) {
+			return true
+		}
+	}
+
+	if c.lookupASN != nil && len(c.asns) > 0 && ip != nil {
+		if asn, err := c.lookupASN(ip); err == nil && c.asns[asn] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,46 @@
+package bots
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/mileusna/useragent"
+)
+
+func TestIsBot(t *testing.T) {
+	cloud := net.ParseIP("203.0.113.7")
+	lookup := func(ip net.IP) (uint, error) {
+		if ip.Equal(cloud) {
+			return 16509, nil
+		}
+		return 0, errors.New("not found")
+	}
+
+	classifier := NewClassifier(append(DefaultUserAgents, "MyMonitor"), []uint{16509}, lookup)
+
+	browser := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36"
+	home := net.ParseIP("198.51.100.20")
+
+	cases := []struct {
+		name      string
+		userAgent string
+		ip        net.IP
+		want      bool
+	}{
+		{"browser", browser, home, false},
+		{"browser in the cloud", browser, cloud, true},
+		{"googlebot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", home, true},
+		{"slack preview", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", home, true},
+		{"link preview", "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", home, true},
+		{"configured", "mymonitor/2.0", home, true},
+		{"curl", "curl/8.4.0", home, true},
+		{"no user agent", "", home, true},
+	}
+
+	for _, c := range cases {
+		if got := classifier.IsBot(c.userAgent, useragent.Parse(c.userAgent), c.ip); got != c.want {
+			t.Errorf("%s: IsBot = %v; want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"log"
+	"time"
+)
+
+type Account struct {
+	Id        int       `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type ApiKey struct {
+	Id         int        `db:"id" json:"id"`
+	AccountId  int        `db:"account_id" json:"account_id"`
+	Prefix     string     `db:"prefix" json:"prefix"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+func (s *service) CreateAccount(name string) (*Account, error) {
+	stmt := `INSERT INTO accounts (name) VALUES ($1) RETURNING id, name, created_at`
+
+	var account Account
+	err := s.db.QueryRow(stmt, name).Scan(&account.Id, &account.Name, &account.CreatedAt)
+	if err != nil {
+		log.Println("[CreateAccount] Insert statment error: ", err)
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (s *service) CreateApiKey(accountId int, prefix string, keyHash string) (*ApiKey, error) {
+	stmt := `INSERT INTO api_keys (account_id, prefix, key_hash) 
+	 VALUES ($1, $2, $3) 
+	 RETURNING id, account_id, prefix, created_at`
+
+	var key ApiKey
+	err := s.db.QueryRow(stmt, accountId, prefix, keyHash).Scan(
+		&key.Id,
+		&key.AccountId,
+		&key.Prefix,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		log.Println("[CreateApiKey] Insert statment error: ", err)
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GetAccountByKeyHash returns the account owning a non revoked API key and
+// marks the key as used. It returns sql.ErrNoRows for unknown keys.
+func (s *service) GetAccountByKeyHash(keyHash string) (*Account, error) {
+	// last_used_at is only written once a minute so busy keys do not turn
+	// every request into a write
+	stmt := `WITH used_key AS (
+		SELECT id, account_id, last_used_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	), touched AS (
+		UPDATE api_keys SET last_used_at = now()
+		FROM used_key
+		WHERE api_keys.id = used_key.id
+		AND (used_key.last_used_at IS NULL OR used_key.last_used_at < now() - interval '1 minute')
+	)
+	SELECT a.id, a.name, a.created_at 
+	FROM accounts a 
+	JOIN used_key ON used_key.account_id = a.id`
+
+	var account Account
+	err := s.db.QueryRow(stmt, keyHash).Scan(&account.Id, &account.Name, &account.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (s *service) ListApiKeys(accountId int) ([]ApiKey, error) {
+	stmt := `SELECT id, account_id, prefix, created_at, last_used_at, revoked_at
+	 FROM api_keys
+	 WHERE account_id = $1
+	 ORDER BY created_at`
+
+	rows, err := s.db.Query(stmt, accountId)
+	if err != nil {
+		log.Println("[ListApiKeys] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []ApiKey = make([]ApiKey, 0)
+
+	for rows.Next() {
+		var key ApiKey
+		if err := rows.Scan(
+			&key.Id,
+			&key.AccountId,
+			&key.Prefix,
+			&key.CreatedAt,
+			&key.LastUsedAt,
+			&key.RevokedAt,
+		); err != nil {
+			log.Println("[ListApiKeys] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeApiKey revokes a key of the account, it reports false when the
+// account has no active key with that id.
+func (s *service) RevokeApiKey(accountId int, keyId int) (bool, error) {
+	stmt := `UPDATE api_keys SET revoked_at = now() 
+	 WHERE id = $1 AND account_id = $2 AND revoked_at IS NULL`
+
+	result, err := s.db.Exec(stmt, keyId, accountId)
+	if err != nil {
+		log.Println("[RevokeApiKey] Update statment error: ", err)
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// AssignLinkOwner hands a link over to an account, used to claim links that
+// were created before links had owners.
+func (s *service) AssignLinkOwner(domain string, shortCode string, accountId int) (bool, error) {
+	stmt := `UPDATE link_map SET owner_id = $1 
+	 WHERE short_code = $2
+	 AND domain_id = (SELECT id FROM domains WHERE hostname IS NOT DISTINCT FROM NULLIF($3, ''))`
+
+	result, err := s.db.Exec(stmt, accountId, shortCode, domain)
+	if err != nil {
+		log.Println("[AssignLinkOwner] Update statment error: ", err)
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ClickBreakdown splits the clicks of a browser, OS or device type further,
+// e.g. by version or device model.
+type ClickBreakdown struct {
+	Name           string `db:"name" json:"name"`
+	ClickCount     int    `db:"click_count" json:"click_count"`
+	UniqueVisitors int    `db:"unique_visitors" json:"unique_visitors"`
+}
+
+type ClicksPerOS struct {
+	OS             string           `db:"os" json:"os"`
+	ClickCount     int              `db:"click_count" json:"click_count"`
+	UniqueVisitors int              `db:"unique_visitors" json:"unique_visitors"`
+	Versions       []ClickBreakdown `json:"versions"`
+}
+
+// ClicksPerDevice splits clicks by device type (mobile, tablet, desktop, bot
+// or other) and within each type by device model.
+type ClicksPerDevice struct {
+	DeviceType     string           `db:"device_type" json:"device_type"`
+	ClickCount     int              `db:"click_count" json:"click_count"`
+	UniqueVisitors int              `db:"unique_visitors" json:"unique_visitors"`
+	Devices        []ClickBreakdown `json:"devices"`
+}
+
+// nestedStat is a group of clicks with its breakdown.
+type nestedStat struct {
+	ClickBreakdown
+	Breakdown []ClickBreakdown
+}
+
+// getNestedStats groups the clicks of a link by column and every group again
+// by subColumn. Groups are sorted by clicks and cut to rng.Top, the breakdown
+// within a group is complete. Both columns are fixed names, never user input.
+func (s *service) getNestedStats(logTag string, column string, subColumn string, linkId int, rng ClickRange) ([]nestedStat, error) {
+	stmt := fmt.Sprintf(`WITH groups AS (
+		SELECT %[1]s AS name, COUNT(*) AS click_count, `+uniqueVisitors+` AS unique_visitors
+		FROM clicks
+		WHERE link_id=$1
+		AND `+rng.filter()+`
+		GROUP BY %[1]s
+		ORDER BY click_count DESC, name
+		LIMIT NULLIF($4, 0)
+	)
+	SELECT g.name, g.click_count, g.unique_visitors, sub.name, sub.click_count, sub.unique_visitors
+	FROM groups g
+	CROSS JOIN LATERAL (
+		SELECT %[2]s AS name, COUNT(*) AS click_count, `+uniqueVisitors+` AS unique_visitors
+		FROM clicks
+		WHERE link_id=$1
+		AND `+rng.filter()+`
+		AND %[1]s = g.name
+		GROUP BY %[2]s
+	) sub
+	ORDER BY g.click_count DESC, g.name, sub.click_count DESC, sub.name;`, column, subColumn)
+
+	rows, err := s.db.Query(stmt, linkId, rng.From, rng.To, rng.Top)
+	if err != nil && err != pgx.ErrNoRows {
+		log.Println(logTag, "error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []nestedStat = make([]nestedStat, 0)
+
+	for rows.Next() {
+		var group, sub ClickBreakdown
+		if err := rows.Scan(
+			&group.Name,
+			&group.ClickCount,
+			&group.UniqueVisitors,
+			&sub.Name,
+			&sub.ClickCount,
+			&sub.UniqueVisitors,
+		); err != nil {
+			log.Println(logTag, "error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		// Rows of a group arrive one after another
+		if len(stats) == 0 || stats[len(stats)-1].Name != group.Name {
+			stats = append(stats, nestedStat{ClickBreakdown: group, Breakdown: make([]ClickBreakdown, 0)})
+		}
+		last := &stats[len(stats)-1]
+		last.Breakdown = append(last.Breakdown, sub)
+	}
+
+	return stats, rows.Err()
+}
+
+func (s *service) GetBrowserStats(linkId int, rng ClickRange) ([]ClicksPerBrowser, error) {
+	stats, err := s.getNestedStats("[GetBrowserStats]", "browser", "browser_version", linkId, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	var clicksPerBrowsers []ClicksPerBrowser = make([]ClicksPerBrowser, 0, len(stats))
+	for _, stat := range stats {
+		clicksPerBrowsers = append(clicksPerBrowsers, ClicksPerBrowser{
+			Browser:        stat.Name,
+			ClickCount:     stat.ClickCount,
+			UniqueVisitors: stat.UniqueVisitors,
+			Versions:       stat.Breakdown,
+		})
+	}
+
+	return clicksPerBrowsers, nil
+}
+
+func (s *service) GetOSStats(linkId int, rng ClickRange) ([]ClicksPerOS, error) {
+	stats, err := s.getNestedStats("[GetOSStats]", "os", "os_version", linkId, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	var clicksPerOSes []ClicksPerOS = make([]ClicksPerOS, 0, len(stats))
+	for _, stat := range stats {
+		clicksPerOSes = append(clicksPerOSes, ClicksPerOS{
+			OS:             stat.Name,
+			ClickCount:     stat.ClickCount,
+			UniqueVisitors: stat.UniqueVisitors,
+			Versions:       stat.Breakdown,
+		})
+	}
+
+	return clicksPerOSes, nil
+}
+
+func (s *service) GetDeviceStats(linkId int, rng ClickRange) ([]ClicksPerDevice, error) {
+	stats, err := s.getNestedStats("[GetDeviceStats]", "device_type", "device", linkId, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	var clicksPerDevices []ClicksPerDevice = make([]ClicksPerDevice, 0, len(stats))
+	for _, stat := range stats {
+		clicksPerDevices = append(clicksPerDevices, ClicksPerDevice{
+			DeviceType:     stat.Name,
+			ClickCount:     stat.ClickCount,
+			UniqueVisitors: stat.UniqueVisitors,
+			Devices:        stat.Breakdown,
+		})
+	}
+
+	return clicksPerDevices, nil
+}
@@ -8,47 +8,226 @@ import (
 )
 
 type Clicks struct {
-	Id             int       `db:"id" json:"id"`
-	ShortCode      string    `db:"short_code" json:"short_code"`
-	Browser        string    `db:"browser" json:"browser"`
-	ClickedAt      time.Time `db:"clicked_at" json:"clicked_at"`
-	UserAgent      string    `db:"user_agent" json:"user_agent"`
-	IpAddr         string    `db:"ip_addr" json:"ip_addr"`
-	Referrer       string    `db:"referrer" json:"referrer"`
-	Country        string    `db:"country" json:"country"`
-	CountryISOCode string    `db:"country_iso_code" json:"country_iso_code"`
+	Id             int    `db:"id" json:"id"`
+	LinkId         int    `db:"link_id" json:"-"`
+	Browser        string `db:"browser" json:"browser"`
+	BrowserVersion string `db:"browser_version" json:"browser_version"`
+	OS             string `db:"os" json:"os"`
+	OSVersion      string `db:"os_version" json:"os_version"`
+	Device         string `db:"device" json:"device"`
+	// DeviceType is mobile, tablet, desktop, bot or other
+	DeviceType string    `db:"device_type" json:"device_type"`
+	ClickedAt  time.Time `db:"clicked_at" json:"clicked_at"`
+	UserAgent  string    `db:"user_agent" json:"user_agent"`
+	IpAddr     string    `db:"ip_addr" json:"ip_addr"`
+	// Referrer is the registrable domain and path of the referrer, or
+	// "direct", RawReferrer what the client sent
+	Referrer         string `db:"referrer" json:"referrer"`
+	RawReferrer      string `db:"raw_referrer" json:"raw_referrer"`
+	ReferrerDomain   string `db:"referrer_domain" json:"referrer_domain"`
+	ReferrerCategory string `db:"referrer_category" json:"referrer_category"`
+	Country          string `db:"country" json:"country"`
+	CountryISOCode   string `db:"country_iso_code" json:"country_iso_code"`
+	// Subdivision, City and the coordinates are only known with the
+	// GeoLite2-City database. Coordinates are rounded to about 10 km.
+	Subdivision        string   `db:"subdivision" json:"subdivision"`
+	SubdivisionISOCode string   `db:"subdivision_iso_code" json:"subdivision_iso_code"`
+	City               string   `db:"city" json:"city"`
+	Latitude           *float64 `db:"latitude" json:"latitude,omitempty"`
+	Longitude          *float64 `db:"longitude" json:"longitude,omitempty"`
+	Revision           int      `db:"revision" json:"revision"`
+	// GeoRuleId is the geo rule that picked the destination, if any
+	GeoRuleId *int `db:"geo_rule_id" json:"geo_rule_id,omitempty"`
+	// DeviceRuleId is the device rule that picked the destination, if any
+	DeviceRuleId *int `db:"device_rule_id" json:"device_rule_id,omitempty"`
+	// VariantId is the A/B split variant that was served, if any
+	VariantId *int `db:"variant_id" json:"variant_id,omitempty"`
+	// Source is how the visitor got the link, "link" unless marked otherwise
+	// e.g. "qr" for scanned QR codes
+	Source string `db:"source" json:"source"`
+	// IsBot marks clicks by crawlers, link previews and monitoring
+	IsBot bool `db:"is_bot" json:"is_bot"`
 }
 
+// BotFilter picks the clicks analytics count by whether they came from bots.
+type BotFilter int
+
+const (
+	// ExcludeBots counts human clicks only
+	ExcludeBots BotFilter = iota
+	IncludeBots
+	OnlyBots
+)
+
+// condition is the is_bot condition of f, empty when every click counts.
+func (f BotFilter) condition() string {
+	switch f {
+	case IncludeBots:
+		return ""
+	case OnlyBots:
+		return "\n\t AND is_bot"
+	default:
+		return "\n\t AND NOT is_bot"
+	}
+}
+
+// ClickRange limits analytics to the clicks between From (inclusive) and To
+// (exclusive), a nil bound leaves that end open.
+type ClickRange struct {
+	From *time.Time
+	To   *time.Time
+	// Interval is the bucket size of timelines: hour, day, week or month
+	Interval string
+	// Location is the time zone buckets start in, UTC when nil
+	Location *time.Location
+	// Top limits breakdowns by browser, referrer or country to the entries
+	// with the most clicks, 0 returns all of them
+	Top int
+	// Bots decides whether bot clicks are counted, they are left out by
+	// default
+	Bots BotFilter
+}
+
+func (r ClickRange) location() *time.Location {
+	if r.Location == nil {
+		return time.UTC
+	}
+
+	return r.Location
+}
+
+func (r ClickRange) interval() string {
+	if r.Interval == "" {
+		return "day"
+	}
+
+	return r.Interval
+}
+
+// uniqueVisitors counts the distinct hashed IPs. ip_addr is hashed with a
+// daily salt, so a visitor coming back on another day counts again. Clicks
+// recorded without a hash are left out.
+const uniqueVisitors = `COUNT(DISTINCT NULLIF(ip_addr, ''))`
+
+// clickRangeFilter is the clicked_at condition of a ClickRange passed as $2
+// and $3. clicked_at holds UTC without a zone, the bounds are converted to
+// UTC instead of the column so the comparison does not depend on the
+// session time zone and can use an index.
+const clickRangeFilter = `($2::timestamptz IS NULL OR clicked_at >= ($2::timestamptz AT TIME ZONE 'UTC'))
+	 AND ($3::timestamptz IS NULL OR clicked_at < ($3::timestamptz AT TIME ZONE 'UTC'))`
+
+// filter is the condition selecting the clicks of r, the bounds are passed
+// as $2 and $3.
+func (r ClickRange) filter() string {
+	return clickRangeFilter + r.Bots.condition()
+}
+
+// ClickTotals sum up the clicks of a link, FirstClick and LastClick are nil
+// when there are none.
+type ClickTotals struct {
+	ClickCount     int        `db:"click_count" json:"click_count"`
+	UniqueVisitors int        `db:"unique_visitors" json:"unique_visitors"`
+	FirstClick     *time.Time `db:"first_click" json:"first_click"`
+	LastClick      *time.Time `db:"last_click" json:"last_click"`
+}
+
+// ClicksPerDay is one bucket of a timeline, Day is the start of the bucket
+// which covers a day unless another interval was asked for.
 type ClicksPerDay struct {
-	Day        time.Time `db:"day" json:"day"`
-	ClickCount int       `db:"click_count" json:"click_count"`
+	Day            time.Time `db:"day" json:"day"`
+	ClickCount     int       `db:"click_count" json:"click_count"`
+	UniqueVisitors int       `db:"unique_visitors" json:"unique_visitors"`
 }
 
 type ClicksPerBrowser struct {
-	Browser    string `db:"browser" json:"browser"`
-	ClickCount int    `db:"click_count" json:"click_count"`
+	Browser        string `db:"browser" json:"browser"`
+	ClickCount     int    `db:"click_count" json:"click_count"`
+	UniqueVisitors int    `db:"unique_visitors" json:"unique_visitors"`
+	// Versions splits the clicks by browser version
+	Versions []ClickBreakdown `json:"versions"`
 }
 
+// TrafficFromReferrer is one entry of the referrer breakdown. Referrer is
+// only set when grouping by referrer, Domain unless grouping by category.
 type TrafficFromReferrer struct {
-	Referrer   string `db:"referrer" json:"referrer"`
-	ClickCount int    `db:"click_count" json:"click_count"`
+	Referrer       string `db:"referrer" json:"referrer,omitempty"`
+	Domain         string `db:"referrer_domain" json:"domain,omitempty"`
+	Category       string `db:"referrer_category" json:"category"`
+	ClickCount     int    `db:"click_count" json:"click_count"`
+	UniqueVisitors int    `db:"unique_visitors" json:"unique_visitors"`
 }
 
+// ReferrerGrouping is what the referrer breakdown groups clicks by.
+type ReferrerGrouping string
+
+const (
+	// GroupByReferrer groups by domain and path
+	GroupByReferrer ReferrerGrouping = "referrer"
+	GroupByDomain   ReferrerGrouping = "domain"
+	GroupByCategory ReferrerGrouping = "category"
+)
+
+// referrerDomain falls back to the referrer for clicks recorded before
+// referrers were normalized that have not been backfilled yet.
+const referrerDomain = `COALESCE(NULLIF(referrer_domain, ''), referrer)`
+
 type TrafficFromCountry struct {
 	CountryISOCode string `db:"country_iso_code" json:"country_iso_code"`
 	ClickCount     int    `db:"click_count" json:"click_count"`
+	UniqueVisitors int    `db:"unique_visitors" json:"unique_visitors"`
+}
+
+// ClicksPerVariant splits the clicks of a link by A/B split variant. Variants
+// that were removed since keep their clicks with an empty url.
+type ClicksPerVariant struct {
+	VariantId  int    `db:"variant_id" json:"variant_id"`
+	Url        string `db:"url" json:"url"`
+	Weight     int    `db:"weight" json:"weight"`
+	ClickCount int    `db:"click_count" json:"click_count"`
+}
+
+type ClicksPerSource struct {
+	Source     string `db:"source" json:"source"`
+	ClickCount int    `db:"click_count" json:"click_count"`
+}
+
+// ClicksPerRevision splits the clicks of a link by the destination that was
+// served. Revision 0 holds clicks recorded before revisions were tracked.
+type ClicksPerRevision struct {
+	Revision   int    `db:"revision" json:"revision"`
+	Url        string `db:"url" json:"url"`
+	ClickCount int    `db:"click_count" json:"click_count"`
 }
 
 func (s *service) LogClick(click Clicks) error {
 	stmt := `INSERT INTO clicks (
-			short_code, 
+			link_id, 
 			ip_addr, 
 			user_agent,
 			browser, 
 			referrer,
 			country,
 			country_iso_code,
-			clicked_at
+			clicked_at,
+			revision,
+			geo_rule_id,
+			device_rule_id,
+			variant_id,
+			source,
+			browser_version,
+			os,
+			os_version,
+			device,
+			device_type,
+			is_bot,
+			subdivision,
+			subdivision_iso_code,
+			city,
+			latitude,
+			longitude,
+			raw_referrer,
+			referrer_domain,
+			referrer_category
 		) VALUES (
 			$1,
 			$2, 
@@ -57,18 +236,57 @@ func (s *service) LogClick(click Clicks) error {
 			$5,
 			$6,
 			$7,
-			$8
+			$8,
+			NULLIF($9, 0),
+			$10,
+			$11,
+			$12,
+			COALESCE(NULLIF($13, ''), 'link'),
+			$14,
+			$15,
+			$16,
+			$17,
+			$18,
+			$19,
+			$20,
+			$21,
+			$22,
+			$23,
+			$24,
+			$25,
+			$26,
+			$27
 		)`
 
 	_, err := s.db.Exec(stmt,
-		click.ShortCode,
+		click.LinkId,
 		click.IpAddr,
 		click.UserAgent,
 		click.Browser,
 		click.Referrer,
 		click.Country,
 		click.CountryISOCode,
-		click.ClickedAt,
+		// clicked_at has no time zone and always holds UTC
+		click.ClickedAt.UTC(),
+		click.Revision,
+		click.GeoRuleId,
+		click.DeviceRuleId,
+		click.VariantId,
+		click.Source,
+		click.BrowserVersion,
+		click.OS,
+		click.OSVersion,
+		click.Device,
+		click.DeviceType,
+		click.IsBot,
+		click.Subdivision,
+		click.SubdivisionISOCode,
+		click.City,
+		click.Latitude,
+		click.Longitude,
+		click.RawReferrer,
+		click.ReferrerDomain,
+		click.ReferrerCategory,
 	)
 	if err != nil {
 		log.Println("[LogClick] Error occured when Executing statement: ", err)
@@ -78,16 +296,38 @@ func (s *service) LogClick(click Clicks) error {
 	return nil
 }
 
-func (s *service) GetClicksOverTime(shortCode string) ([]ClicksPerDay, error) {
-	stmt := `SELECT 
-		DATE_TRUNC('day', clicked_at) AS day, 
-		COUNT(*) AS click_count 
-		FROM clicks 
-		WHERE short_code = $1
-		GROUP BY day 
-		ORDER BY day;`
+// GetClicksOverTime buckets the clicks of a link by the interval of rng.
+// Buckets are aligned to the local time of rng.Location and run from the
+// start of the range, or the first click, until the end of the range or now.
+// Buckets without clicks are included with a count of 0.
+func (s *service) GetClicksOverTime(linkId int, rng ClickRange) ([]ClicksPerDay, error) {
+	// The series is generated in local time so a day stays a calendar day
+	// across daylight saving changes. clicked_at holds UTC, it is first made
+	// a timestamptz and then converted to the local time of $5.
+	stmt := `WITH counts AS (
+		SELECT DATE_TRUNC($4::text, (clicked_at AT TIME ZONE 'UTC') AT TIME ZONE $5::text) AS bucket,
+		COUNT(*) AS click_count,
+		` + uniqueVisitors + ` AS unique_visitors
+		FROM clicks
+		WHERE link_id = $1
+		AND ` + rng.filter() + `
+		GROUP BY bucket
+	), bounds AS (
+		SELECT
+		COALESCE(DATE_TRUNC($4::text, $2::timestamptz AT TIME ZONE $5::text), MIN(bucket)) AS first_bucket,
+		DATE_TRUNC($4::text, COALESCE($3::timestamptz - interval '1 microsecond', now()) AT TIME ZONE $5::text) AS last_bucket
+		FROM counts
+	)
+	SELECT series.bucket AT TIME ZONE $5::text AS day,
+	COALESCE(counts.click_count, 0) AS click_count,
+	COALESCE(counts.unique_visitors, 0) AS unique_visitors
+	FROM bounds
+	CROSS JOIN generate_series(bounds.first_bucket, bounds.last_bucket, ('1 ' || $4::text)::interval) AS series(bucket)
+	LEFT JOIN counts ON counts.bucket = series.bucket
+	ORDER BY series.bucket;`
 
-	rows, err := s.db.Query(stmt, shortCode)
+	location := rng.location()
+	rows, err := s.db.Query(stmt, linkId, rng.From, rng.To, rng.interval(), location.String())
 	if err != nil && err != pgx.ErrNoRows {
 		log.Println("[GetClicksOverTime] error occured while querying", rows)
 		return nil, err
@@ -98,10 +338,11 @@ func (s *service) GetClicksOverTime(shortCode string) ([]ClicksPerDay, error) {
 
 	for rows.Next() {
 		var clicksPerDay ClicksPerDay
-		if err := rows.Scan(&clicksPerDay.Day, &clicksPerDay.ClickCount); err != nil {
+		if err := rows.Scan(&clicksPerDay.Day, &clicksPerDay.ClickCount, &clicksPerDay.UniqueVisitors); err != nil {
 			log.Println("[GetClicksOverTime] error occured while scanning to variable", err)
 			return nil, err
 		}
+		clicksPerDay.Day = clicksPerDay.Day.In(location)
 
 		clicksPerDays = append(clicksPerDays, clicksPerDay)
 	}
@@ -109,41 +350,31 @@ func (s *service) GetClicksOverTime(shortCode string) ([]ClicksPerDay, error) {
 	return clicksPerDays, nil
 }
 
-func (s *service) GetBrowserStats(shortCode string) ([]ClicksPerBrowser, error) {
-	stmt := `SELECT browser, COUNT(*) AS click_count
-					 FROM clicks
-					 WHERE short_code=$1
-					 GROUP BY browser
-					 ORDER BY click_count DESC;`
-	rows, err := s.db.Query(stmt, shortCode)
-	if err != nil && err != pgx.ErrNoRows {
-		log.Println("[GetBrowserStats] error occured while querying", rows)
-		return nil, err
-	}
-	defer rows.Close()
-
-	var clicksPerBrowsers []ClicksPerBrowser = make([]ClicksPerBrowser, 0)
-
-	for rows.Next() {
-		var clicksPerBrowser ClicksPerBrowser
-		if err := rows.Scan(&clicksPerBrowser.Browser, &clicksPerBrowser.ClickCount); err != nil {
-			log.Println("[GetBrowserStats] error occured while scanning to variable", err)
-			return nil, err
-		}
-
-		clicksPerBrowsers = append(clicksPerBrowsers, clicksPerBrowser)
+// GetReferrerStats splits the clicks of a link by referrer, domain or
+// category. Rules can change the category of a domain over time, MAX picks
+// one of them so every referrer and domain is listed once.
+func (s *service) GetReferrerStats(linkId int, rng ClickRange, groupBy ReferrerGrouping) ([]TrafficFromReferrer, error) {
+	var columns, group string
+	switch groupBy {
+	case GroupByDomain:
+		columns = `'', ` + referrerDomain + `, MAX(referrer_category)`
+		group = referrerDomain
+	case GroupByCategory:
+		columns = `'', '', referrer_category`
+		group = `referrer_category`
+	default:
+		columns = `referrer, MAX(` + referrerDomain + `), MAX(referrer_category)`
+		group = `referrer`
 	}
 
-	return clicksPerBrowsers, nil
-}
-
-func (s *service) GetReferrerStats(shortCode string) ([]TrafficFromReferrer, error) {
-	stmt := `SELECT referrer, COUNT(*) AS click_count
+	stmt := `SELECT ` + columns + `, COUNT(*) AS click_count, ` + uniqueVisitors + ` AS unique_visitors
 						FROM clicks
-						WHERE short_code=$1
-						GROUP BY referrer
-						ORDER BY click_count DESC;`
-	rows, err := s.db.Query(stmt, shortCode)
+						WHERE link_id=$1
+					 AND ` + rng.filter() + `
+						GROUP BY ` + group + `
+						ORDER BY click_count DESC
+					 LIMIT NULLIF($4, 0);`
+	rows, err := s.db.Query(stmt, linkId, rng.From, rng.To, rng.Top)
 	if err != nil && err != pgx.ErrNoRows {
 		log.Println("[GetReferrerStats] error occured while querying", rows)
 		return nil, err
@@ -154,7 +385,13 @@ func (s *service) GetReferrerStats(shortCode string) ([]TrafficFromReferrer, err
 
 	for rows.Next() {
 		var trafficFromReferrer TrafficFromReferrer
-		if err := rows.Scan(&trafficFromReferrer.Referrer, &trafficFromReferrer.ClickCount); err != nil {
+		if err := rows.Scan(
+			&trafficFromReferrer.Referrer,
+			&trafficFromReferrer.Domain,
+			&trafficFromReferrer.Category,
+			&trafficFromReferrer.ClickCount,
+			&trafficFromReferrer.UniqueVisitors,
+		); err != nil {
 			log.Println("[GetReferrerStats] error occured while scanning to variable", err)
 			return nil, err
 		}
@@ -165,13 +402,15 @@ func (s *service) GetReferrerStats(shortCode string) ([]TrafficFromReferrer, err
 	return trafficFromReferrers, nil
 }
 
-func (s *service) GetCountryStats(shortCode string) ([]TrafficFromCountry, error) {
-	stmt := `SELECT country_iso_code, COUNT(*) AS click_count
+func (s *service) GetCountryStats(linkId int, rng ClickRange) ([]TrafficFromCountry, error) {
+	stmt := `SELECT country_iso_code, COUNT(*) AS click_count, ` + uniqueVisitors + ` AS unique_visitors
 						FROM clicks
-						WHERE short_code=$1
+						WHERE link_id=$1
+					 AND ` + rng.filter() + `
 						GROUP BY country_iso_code
-						ORDER BY click_count DESC;`
-	rows, err := s.db.Query(stmt, shortCode)
+						ORDER BY click_count DESC
+					 LIMIT NULLIF($4, 0);`
+	rows, err := s.db.Query(stmt, linkId, rng.From, rng.To, rng.Top)
 	if err != nil && err != pgx.ErrNoRows {
 		log.Println("[GetCountryStats] error occured while querying", rows)
 		return nil, err
@@ -182,7 +421,7 @@ func (s *service) GetCountryStats(shortCode string) ([]TrafficFromCountry, error
 
 	for rows.Next() {
 		var trafficFromCountry TrafficFromCountry
-		if err := rows.Scan(&trafficFromCountry.CountryISOCode, &trafficFromCountry.ClickCount); err != nil {
+		if err := rows.Scan(&trafficFromCountry.CountryISOCode, &trafficFromCountry.ClickCount, &trafficFromCountry.UniqueVisitors); err != nil {
 			log.Println("[GetCountryStats] error occured while scanning to variable", err)
 			return nil, err
 		}
@@ -192,3 +431,168 @@ func (s *service) GetCountryStats(shortCode string) ([]TrafficFromCountry, error
 
 	return trafficFromCountries, nil
 }
+
+func (s *service) GetRevisionStats(linkId int, rng ClickRange) ([]ClicksPerRevision, error) {
+	stmt := `SELECT COALESCE(c.revision, 0) AS rev, COALESCE(r.url, ''), COUNT(*) AS click_count
+						FROM clicks c
+						LEFT JOIN link_revisions r 
+						ON r.link_id = c.link_id AND r.revision = c.revision
+						WHERE c.link_id=$1
+						AND ` + rng.filter() + `
+						GROUP BY rev, r.url
+						ORDER BY rev;`
+	rows, err := s.db.Query(stmt, linkId, rng.From, rng.To)
+	if err != nil && err != pgx.ErrNoRows {
+		log.Println("[GetRevisionStats] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clicksPerRevisions []ClicksPerRevision = make([]ClicksPerRevision, 0)
+
+	for rows.Next() {
+		var clicksPerRevision ClicksPerRevision
+		if err := rows.Scan(&clicksPerRevision.Revision, &clicksPerRevision.Url, &clicksPerRevision.ClickCount); err != nil {
+			log.Println("[GetRevisionStats] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		clicksPerRevisions = append(clicksPerRevisions, clicksPerRevision)
+	}
+
+	return clicksPerRevisions, rows.Err()
+}
+
+func (s *service) GetVariantStats(linkId int, rng ClickRange) ([]ClicksPerVariant, error) {
+	stmt := `SELECT c.variant_id, COALESCE(v.url, ''), COALESCE(v.weight, 0), COUNT(*) AS click_count
+						FROM clicks c
+						LEFT JOIN link_variants v ON v.id = c.variant_id
+						WHERE c.link_id=$1 AND c.variant_id IS NOT NULL
+						AND ` + rng.filter() + `
+						GROUP BY c.variant_id, v.url, v.weight
+						ORDER BY c.variant_id;`
+	rows, err := s.db.Query(stmt, linkId, rng.From, rng.To)
+	if err != nil && err != pgx.ErrNoRows {
+		log.Println("[GetVariantStats] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clicksPerVariants []ClicksPerVariant = make([]ClicksPerVariant, 0)
+
+	for rows.Next() {
+		var clicksPerVariant ClicksPerVariant
+		if err := rows.Scan(
+			&clicksPerVariant.VariantId,
+			&clicksPerVariant.Url,
+			&clicksPerVariant.Weight,
+			&clicksPerVariant.ClickCount,
+		); err != nil {
+			log.Println("[GetVariantStats] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		clicksPerVariants = append(clicksPerVariants, clicksPerVariant)
+	}
+
+	return clicksPerVariants, rows.Err()
+}
+
+func (s *service) GetSourceStats(linkId int, rng ClickRange) ([]ClicksPerSource, error) {
+	stmt := `SELECT source, COUNT(*) AS click_count
+						FROM clicks
+						WHERE link_id=$1
+						AND ` + rng.filter() + `
+						GROUP BY source
+						ORDER BY click_count DESC;`
+	rows, err := s.db.Query(stmt, linkId, rng.From, rng.To)
+	if err != nil && err != pgx.ErrNoRows {
+		log.Println("[GetSourceStats] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clicksPerSources []ClicksPerSource = make([]ClicksPerSource, 0)
+
+	for rows.Next() {
+		var clicksPerSource ClicksPerSource
+		if err := rows.Scan(
+			&clicksPerSource.Source,
+			&clicksPerSource.ClickCount,
+		); err != nil {
+			log.Println("[GetSourceStats] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		clicksPerSources = append(clicksPerSources, clicksPerSource)
+	}
+
+	return clicksPerSources, rows.Err()
+}
+
+// GetClickTotals counts the clicks and unique visitors of a link within rng.
+func (s *service) GetClickTotals(linkId int, rng ClickRange) (*ClickTotals, error) {
+	stmt := `SELECT COUNT(*), ` + uniqueVisitors + `, MIN(clicked_at), MAX(clicked_at)
+						FROM clicks
+						WHERE link_id=$1
+						AND ` + rng.filter()
+
+	var totals ClickTotals
+	err := s.db.QueryRow(stmt, linkId, rng.From, rng.To).Scan(
+		&totals.ClickCount,
+		&totals.UniqueVisitors,
+		&totals.FirstClick,
+		&totals.LastClick,
+	)
+	if err != nil {
+		log.Println("[GetClickTotals] error occured while querying", err)
+		return nil, err
+	}
+
+	return &totals, nil
+}
+
+// ListUnclassifiedReferrers returns up to limit distinct raw referrers of
+// clicks recorded before referrers were normalized.
+func (s *service) ListUnclassifiedReferrers(limit int) ([]string, error) {
+	stmt := `SELECT DISTINCT raw_referrer
+						FROM clicks
+						WHERE referrer_domain = ''
+						LIMIT $1;`
+	rows, err := s.db.Query(stmt, limit)
+	if err != nil {
+		log.Println("[ListUnclassifiedReferrers] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var referrers []string = make([]string, 0)
+
+	for rows.Next() {
+		var referrer string
+		if err := rows.Scan(&referrer); err != nil {
+			log.Println("[ListUnclassifiedReferrers] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		referrers = append(referrers, referrer)
+	}
+
+	return referrers, rows.Err()
+}
+
+// ClassifyReferrer stores the normalized referrer, domain and category on
+// the unclassified clicks with the raw referrer rawReferrer and returns how
+// many were updated.
+func (s *service) ClassifyReferrer(rawReferrer string, referrer string, domain string, category string) (int64, error) {
+	stmt := `UPDATE clicks
+						SET referrer = $2, referrer_domain = $3, referrer_category = $4
+						WHERE referrer_domain = '' AND raw_referrer = $1;`
+	result, err := s.db.Exec(stmt, rawReferrer, referrer, domain, category)
+	if err != nil {
+		log.Println("[ClassifyReferrer] error occured while updating", err)
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// migrate applies the Up section of every migration in order.
+func migrate(t *testing.T, s *service) {
+	t.Helper()
+
+	files, err := filepath.Glob("../../migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		up, _, _ := strings.Cut(string(content), "-- +goose Down")
+		if _, err := s.db.Exec(up); err != nil {
+			t.Fatalf("could not apply %s: %v", filepath.Base(file), err)
+		}
+	}
+}
+
+func TestGetClicksOverTimeLocation(t *testing.T) {
+	s := New().(*service)
+	migrate(t, s)
+
+	var linkId int
+	err := s.db.QueryRow(`INSERT INTO link_map (short_code, url, domain_id)
+		VALUES ('berlin', 'https://example.com', (SELECT id FROM domains WHERE hostname IS NULL))
+		RETURNING id`).Scan(&linkId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 23:30 and 00:30 in Berlin, one click on each side of local midnight
+	for _, at := range []string{"2026-03-01T22:30:00Z", "2026-03-01T23:30:00Z"} {
+		clickedAt, _ := time.Parse(time.RFC3339, at)
+		if err := s.LogClick(Clicks{LinkId: linkId, ClickedAt: clickedAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, berlin)
+	to := time.Date(2026, 3, 3, 0, 0, 0, 0, berlin)
+
+	days, err := s.GetClicksOverTime(linkId, ClickRange{From: &from, To: &to, Interval: "day", Location: berlin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(days) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(days))
+	}
+	for i, day := range days {
+		expected := from.AddDate(0, 0, i)
+		if !day.Day.Equal(expected) {
+			t.Errorf("expected day %d to start at %s, got %s", i, expected, day.Day)
+		}
+		if day.ClickCount != 1 {
+			t.Errorf("expected 1 click on %s, got %d", expected, day.ClickCount)
+		}
+	}
+}
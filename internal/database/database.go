@@ -18,15 +18,59 @@ type Service interface {
 	// Health returns a map of health status information.
 	// The keys and values in the map are service-specific.
 	Health() map[string]string
-	GetLink(id string) (*LinkMap, error)
+	GetLink(domain string, shortCode string) (*LinkMap, error)
+	GetLinkForHost(host string, shortCode string) (*LinkMap, error)
 	InsertShortenedLink(link LinkMap) error
-	UpdateShortenedLink(shortCode string, destUrl string) error
+	InsertShortenedLinks(links []BulkLink, codes CodeSource) error
+	NextShortCodeId() (uint64, error)
+	UpdateLink(linkId int, update LinkUpdate) error
+	IncrementClickCount(linkId int) (bool, error)
+	SetLinkDeleted(linkId int, deleted bool) (bool, error)
+	SetLinkDisabled(linkId int, disabled bool) (bool, error)
+
+	GetGeoRules(linkId int) ([]GeoRule, error)
+	ReplaceGeoRules(linkId int, rules []GeoRule) error
+	GetDeviceRules(linkId int) ([]DeviceRule, error)
+	ReplaceDeviceRules(linkId int, rules []DeviceRule) error
+	GetVariants(linkId int) ([]Variant, error)
+	ReplaceVariants(linkId int, variants []Variant) error
+	GetLinkRules(linkId int) (*LinkRules, error)
+
+	GetLinkRevisions(linkId int) ([]LinkRevision, error)
+	RollbackLink(linkId int, revision int) (*LinkRevision, error)
+
+	ListLinks(params ListLinksParams) ([]LinkMap, *LinkCursor, error)
+	SetLinkTags(accountId int, linkId int, names []string) error
+	GetTags(accountId int) ([]Tag, error)
+
+	CreateAccount(name string) (*Account, error)
+	CreateApiKey(accountId int, prefix string, keyHash string) (*ApiKey, error)
+	GetAccountByKeyHash(keyHash string) (*Account, error)
+	ListApiKeys(accountId int) ([]ApiKey, error)
+	RevokeApiKey(accountId int, keyId int) (bool, error)
+	AssignLinkOwner(domain string, shortCode string, accountId int) (bool, error)
+
+	CreateDomain(hostname string, accountId *int) (*Domain, error)
+	GetDomain(hostname string) (*Domain, error)
+	ListDomains(accountId int) ([]Domain, error)
+	GetDomainStats(accountId int, bots BotFilter) ([]ClicksPerDomain, error)
 
 	LogClick(click Clicks) error
-	GetClicksOverTime(shortCode string) ([]ClicksPerDay, error)
-	GetBrowserStats(shortCode string) ([]ClicksPerBrowser, error)
-	GetReferrerStats(shortCode string) ([]TrafficFromReferrer, error)
-	GetCountryStats(shortCode string) ([]TrafficFromCountry, error)
+	ListUnclassifiedReferrers(limit int) ([]string, error)
+	ClassifyReferrer(rawReferrer string, referrer string, domain string, category string) (int64, error)
+	GetClickTotals(linkId int, rng ClickRange) (*ClickTotals, error)
+	GetClicksOverTime(linkId int, rng ClickRange) ([]ClicksPerDay, error)
+	GetBrowserStats(linkId int, rng ClickRange) ([]ClicksPerBrowser, error)
+	GetOSStats(linkId int, rng ClickRange) ([]ClicksPerOS, error)
+	GetDeviceStats(linkId int, rng ClickRange) ([]ClicksPerDevice, error)
+	GetReferrerStats(linkId int, rng ClickRange, groupBy ReferrerGrouping) ([]TrafficFromReferrer, error)
+	GetCountryStats(linkId int, rng ClickRange) ([]TrafficFromCountry, error)
+	GetRegionStats(linkId int, rng ClickRange) ([]ClicksPerRegion, error)
+	GetCityStats(linkId int, rng ClickRange) ([]ClicksPerCity, error)
+	GetRevisionStats(linkId int, rng ClickRange) ([]ClicksPerRevision, error)
+	GetVariantStats(linkId int, rng ClickRange) ([]ClicksPerVariant, error)
+	GetSourceStats(linkId int, rng ClickRange) ([]ClicksPerSource, error)
+	ExportClicks(ctx context.Context, accountId int, linkId int, rng ClickRange, fn func(click *ExportedClick) error) error
 	// Close terminates the database connection.
 	// It returns an error if the connection cannot be closed.
 	Close() error
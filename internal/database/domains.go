@@ -0,0 +1,121 @@
+package database
+
+import (
+	"log"
+	"time"
+)
+
+// Domain is a hostname short links can be served from. The default domain
+// is the one of FRONTEND_URL and has an empty hostname.
+type Domain struct {
+	Id       int    `db:"id" json:"id"`
+	Hostname string `db:"hostname" json:"hostname"`
+	// AccountId restricts the domain to one account, nil when every account
+	// may use it
+	AccountId *int      `db:"account_id" json:"-"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type ClicksPerDomain struct {
+	Hostname   string `db:"hostname" json:"hostname"`
+	LinkCount  int    `db:"link_count" json:"link_count"`
+	ClickCount int    `db:"click_count" json:"click_count"`
+}
+
+const domainColumns = `id, COALESCE(hostname, ''), account_id, created_at`
+
+func scanDomain(row rowScanner, domain *Domain) error {
+	return row.Scan(&domain.Id, &domain.Hostname, &domain.AccountId, &domain.CreatedAt)
+}
+
+func (s *service) CreateDomain(hostname string, accountId *int) (*Domain, error) {
+	stmt := `INSERT INTO domains (hostname, account_id) VALUES ($1, $2)
+	 RETURNING ` + domainColumns
+
+	var domain Domain
+	if err := scanDomain(s.db.QueryRow(stmt, hostname, accountId), &domain); err != nil {
+		log.Println("[CreateDomain] Insert statment error: ", err)
+		return nil, err
+	}
+
+	return &domain, nil
+}
+
+// GetDomain looks up a domain by hostname, an empty hostname returns the
+// default domain. It returns sql.ErrNoRows for unknown hostnames.
+func (s *service) GetDomain(hostname string) (*Domain, error) {
+	stmt := `SELECT ` + domainColumns + ` FROM domains 
+	 WHERE hostname IS NOT DISTINCT FROM NULLIF($1, '')`
+
+	var domain Domain
+	if err := scanDomain(s.db.QueryRow(stmt, hostname), &domain); err != nil {
+		return nil, err
+	}
+
+	return &domain, nil
+}
+
+// ListDomains returns the domains an account may create links on, the
+// default domain first.
+func (s *service) ListDomains(accountId int) ([]Domain, error) {
+	stmt := `SELECT ` + domainColumns + ` FROM domains
+	 WHERE account_id IS NULL OR account_id = $1
+	 ORDER BY hostname NULLS FIRST`
+
+	rows, err := s.db.Query(stmt, accountId)
+	if err != nil {
+		log.Println("[ListDomains] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []Domain = make([]Domain, 0)
+
+	for rows.Next() {
+		var domain Domain
+		if err := scanDomain(rows, &domain); err != nil {
+			log.Println("[ListDomains] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		domains = append(domains, domain)
+	}
+
+	return domains, rows.Err()
+}
+
+// GetDomainStats splits the links and clicks of an account by the domain
+// the links are served from, bots decides which clicks are counted.
+func (s *service) GetDomainStats(accountId int, bots BotFilter) ([]ClicksPerDomain, error) {
+	stmt := `SELECT COALESCE(d.hostname, ''), COUNT(DISTINCT l.id) AS link_count, COUNT(c.id) AS click_count
+						FROM link_map l
+						JOIN domains d ON d.id = l.domain_id
+						LEFT JOIN clicks c ON c.link_id = l.id` + bots.condition() + `
+						WHERE l.owner_id = $1
+						GROUP BY d.hostname
+						ORDER BY click_count DESC;`
+	rows, err := s.db.Query(stmt, accountId)
+	if err != nil {
+		log.Println("[GetDomainStats] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clicksPerDomains []ClicksPerDomain = make([]ClicksPerDomain, 0)
+
+	for rows.Next() {
+		var clicksPerDomain ClicksPerDomain
+		if err := rows.Scan(
+			&clicksPerDomain.Hostname,
+			&clicksPerDomain.LinkCount,
+			&clicksPerDomain.ClickCount,
+		); err != nil {
+			log.Println("[GetDomainStats] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		clicksPerDomains = append(clicksPerDomains, clicksPerDomain)
+	}
+
+	return clicksPerDomains, rows.Err()
+}
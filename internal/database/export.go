@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// exportFetchSize is the number of rows fetched from the export cursor at
+// once, only that many clicks are held in memory.
+const exportFetchSize = 1000
+
+// ExportedClick is a raw click with the link it belongs to.
+type ExportedClick struct {
+	Clicks
+	Domain    string `db:"domain" json:"domain"`
+	ShortCode string `db:"short_code" json:"short_code"`
+}
+
+// ExportClicks streams the clicks of an account within rng, oldest first, to
+// fn. linkId limits the export to one link, 0 exports every link of the
+// account. The rows are read through a server-side cursor in a read only
+// transaction so exports of any size run in constant memory. An error
+// returned by fn stops the export and is returned.
+func (s *service) ExportClicks(ctx context.Context, accountId int, linkId int, rng ClickRange, fn func(click *ExportedClick) error) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		log.Println("[ExportClicks] Transaction start error: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `DECLARE click_export NO SCROLL CURSOR FOR
+		SELECT c.id, c.clicked_at, COALESCE(d.hostname, ''), l.short_code,
+			COALESCE(c.ip_addr, ''), COALESCE(c.user_agent, ''), COALESCE(c.browser, ''),
+			c.browser_version, c.os, c.os_version, c.device, c.device_type, c.is_bot,
+			COALESCE(c.referrer, ''), c.raw_referrer, c.referrer_domain, c.referrer_category,
+			COALESCE(c.country, ''), COALESCE(c.country_iso_code, ''),
+			c.subdivision, c.subdivision_iso_code, c.city, c.latitude, c.longitude,
+			c.source, COALESCE(c.revision, 0), c.variant_id, c.geo_rule_id, c.device_rule_id
+		FROM clicks c
+		JOIN link_map l ON l.id = c.link_id
+		JOIN domains d ON d.id = l.domain_id
+		WHERE l.owner_id = $4
+		AND ($1::int = 0 OR c.link_id = $1::int)
+		AND ` + rng.filter() + `
+		ORDER BY c.clicked_at, c.id`
+	if _, err := tx.ExecContext(ctx, stmt, linkId, rng.From, rng.To, accountId); err != nil {
+		log.Println("[ExportClicks] Declare cursor error: ", err)
+		return err
+	}
+
+	fetch := fmt.Sprintf(`FETCH FORWARD %d FROM click_export`, exportFetchSize)
+	for {
+		fetched, err := fetchClicks(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if fetched < exportFetchSize {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
+
+// fetchClicks passes the next batch of the export cursor to fn and returns
+// how many rows it had.
+func fetchClicks(ctx context.Context, tx *sql.Tx, fetch string, fn func(click *ExportedClick) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		log.Println("[ExportClicks] Fetch error: ", err)
+		return 0, err
+	}
+	defer rows.Close()
+
+	fetched := 0
+	for rows.Next() {
+		var click ExportedClick
+		if err := rows.Scan(
+			&click.Id,
+			&click.ClickedAt,
+			&click.Domain,
+			&click.ShortCode,
+			&click.IpAddr,
+			&click.UserAgent,
+			&click.Browser,
+			&click.BrowserVersion,
+			&click.OS,
+			&click.OSVersion,
+			&click.Device,
+			&click.DeviceType,
+			&click.IsBot,
+			&click.Referrer,
+			&click.RawReferrer,
+			&click.ReferrerDomain,
+			&click.ReferrerCategory,
+			&click.Country,
+			&click.CountryISOCode,
+			&click.Subdivision,
+			&click.SubdivisionISOCode,
+			&click.City,
+			&click.Latitude,
+			&click.Longitude,
+			&click.Source,
+			&click.Revision,
+			&click.VariantId,
+			&click.GeoRuleId,
+			&click.DeviceRuleId,
+		); err != nil {
+			log.Println("[ExportClicks] error occured while scanning to variable", err)
+			return fetched, err
+		}
+
+		if err := fn(&click); err != nil {
+			return fetched, err
+		}
+		fetched++
+	}
+
+	return fetched, rows.Err()
+}
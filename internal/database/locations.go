@@ -0,0 +1,112 @@
+package database
+
+import (
+	"log"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ClicksPerRegion splits the clicks of a link by subdivision, e.g. a state or
+// province. Only clicks located with the GeoLite2-City database are counted.
+type ClicksPerRegion struct {
+	CountryISOCode     string `db:"country_iso_code" json:"country_iso_code"`
+	Subdivision        string `db:"subdivision" json:"subdivision"`
+	SubdivisionISOCode string `db:"subdivision_iso_code" json:"subdivision_iso_code"`
+	ClickCount         int    `db:"click_count" json:"click_count"`
+	UniqueVisitors     int    `db:"unique_visitors" json:"unique_visitors"`
+}
+
+// ClicksPerCity splits the clicks of a link by city. Latitude and Longitude
+// are the rounded coordinates recorded with the clicks.
+type ClicksPerCity struct {
+	CountryISOCode string   `db:"country_iso_code" json:"country_iso_code"`
+	Subdivision    string   `db:"subdivision" json:"subdivision"`
+	City           string   `db:"city" json:"city"`
+	Latitude       *float64 `db:"latitude" json:"latitude"`
+	Longitude      *float64 `db:"longitude" json:"longitude"`
+	ClickCount     int      `db:"click_count" json:"click_count"`
+	UniqueVisitors int      `db:"unique_visitors" json:"unique_visitors"`
+}
+
+func (s *service) GetRegionStats(linkId int, rng ClickRange) ([]ClicksPerRegion, error) {
+	stmt := `SELECT country_iso_code, subdivision, subdivision_iso_code,
+						COUNT(*) AS click_count, ` + uniqueVisitors + ` AS unique_visitors
+						FROM clicks
+						WHERE link_id=$1
+						AND subdivision <> ''
+						AND ` + rng.filter() + `
+						GROUP BY country_iso_code, subdivision, subdivision_iso_code
+						ORDER BY click_count DESC
+						LIMIT NULLIF($4, 0);`
+	rows, err := s.db.Query(stmt, linkId, rng.From, rng.To, rng.Top)
+	if err != nil && err != pgx.ErrNoRows {
+		log.Println("[GetRegionStats] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clicksPerRegions []ClicksPerRegion = make([]ClicksPerRegion, 0)
+
+	for rows.Next() {
+		var clicksPerRegion ClicksPerRegion
+		if err := rows.Scan(
+			&clicksPerRegion.CountryISOCode,
+			&clicksPerRegion.Subdivision,
+			&clicksPerRegion.SubdivisionISOCode,
+			&clicksPerRegion.ClickCount,
+			&clicksPerRegion.UniqueVisitors,
+		); err != nil {
+			log.Println("[GetRegionStats] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		clicksPerRegions = append(clicksPerRegions, clicksPerRegion)
+	}
+
+	return clicksPerRegions, rows.Err()
+}
+
+// GetCityStats groups by the city name within its subdivision, coordinates
+// of one city can move a little between database releases so they are
+// averaged and rounded again.
+func (s *service) GetCityStats(linkId int, rng ClickRange) ([]ClicksPerCity, error) {
+	stmt := `SELECT country_iso_code, subdivision, city,
+						ROUND(AVG(latitude)::numeric, 1)::float8 AS latitude,
+						ROUND(AVG(longitude)::numeric, 1)::float8 AS longitude,
+						COUNT(*) AS click_count, ` + uniqueVisitors + ` AS unique_visitors
+						FROM clicks
+						WHERE link_id=$1
+						AND city <> ''
+						AND ` + rng.filter() + `
+						GROUP BY country_iso_code, subdivision, city
+						ORDER BY click_count DESC
+						LIMIT NULLIF($4, 0);`
+	rows, err := s.db.Query(stmt, linkId, rng.From, rng.To, rng.Top)
+	if err != nil && err != pgx.ErrNoRows {
+		log.Println("[GetCityStats] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clicksPerCities []ClicksPerCity = make([]ClicksPerCity, 0)
+
+	for rows.Next() {
+		var clicksPerCity ClicksPerCity
+		if err := rows.Scan(
+			&clicksPerCity.CountryISOCode,
+			&clicksPerCity.Subdivision,
+			&clicksPerCity.City,
+			&clicksPerCity.Latitude,
+			&clicksPerCity.Longitude,
+			&clicksPerCity.ClickCount,
+			&clicksPerCity.UniqueVisitors,
+		); err != nil {
+			log.Println("[GetCityStats] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		clicksPerCities = append(clicksPerCities, clicksPerCity)
+	}
+
+	return clicksPerCities, rows.Err()
+}
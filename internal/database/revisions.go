@@ -0,0 +1,85 @@
+package database
+
+import (
+	"log"
+	"time"
+)
+
+type LinkRevision struct {
+	Id       int    `db:"id" json:"id"`
+	LinkId   int    `db:"link_id" json:"-"`
+	Revision int    `db:"revision" json:"revision"`
+	Url      string `db:"url" json:"url"`
+	// OriginalUrl is the shortener url Url was resolved from
+	OriginalUrl *string   `db:"original_url" json:"original_url,omitempty"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+func (s *service) GetLinkRevisions(linkId int) ([]LinkRevision, error) {
+	stmt := `SELECT id, link_id, revision, url, original_url, created_at
+	 FROM link_revisions
+	 WHERE link_id = $1
+	 ORDER BY revision DESC`
+
+	rows, err := s.db.Query(stmt, linkId)
+	if err != nil {
+		log.Println("[GetLinkRevisions] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []LinkRevision = make([]LinkRevision, 0)
+
+	for rows.Next() {
+		var revision LinkRevision
+		if err := rows.Scan(
+			&revision.Id,
+			&revision.LinkId,
+			&revision.Revision,
+			&revision.Url,
+			&revision.OriginalUrl,
+			&revision.CreatedAt,
+		); err != nil {
+			log.Println("[GetLinkRevisions] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// RollbackLink points a link back to the destination of an older revision.
+// The rollback itself is recorded as a new revision so history is never
+// rewritten. It returns sql.ErrNoRows when the revision does not exist.
+func (s *service) RollbackLink(linkId int, revision int) (*LinkRevision, error) {
+	stmt := `WITH target AS (
+		SELECT url, original_url FROM link_revisions WHERE link_id = $1 AND revision = $2
+	), updated AS (
+		UPDATE link_map 
+		SET url = target.url, original_url = target.original_url, revision = link_map.revision + 1, updated_at = now()
+		FROM target
+		WHERE link_map.id = $1
+		RETURNING link_map.id, link_map.revision, link_map.url, link_map.original_url
+	)
+	INSERT INTO link_revisions (link_id, revision, url, original_url)
+	SELECT id, revision, url, original_url FROM updated
+	RETURNING id, link_id, revision, url, original_url, created_at`
+
+	var rev LinkRevision
+	err := s.db.QueryRow(stmt, linkId, revision).Scan(
+		&rev.Id,
+		&rev.LinkId,
+		&rev.Revision,
+		&rev.Url,
+		&rev.OriginalUrl,
+		&rev.CreatedAt,
+	)
+	if err != nil {
+		log.Println("[RollbackLink] error occured while rolling back: ", err)
+		return nil, err
+	}
+
+	return &rev, nil
+}
@@ -0,0 +1,303 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// GeoRule sends visitors from one country to an alternate destination.
+type GeoRule struct {
+	Id             int    `db:"id" json:"id"`
+	LinkId         int    `db:"link_id" json:"-"`
+	CountryISOCode string `db:"country_iso_code" json:"country_iso_code"`
+	Url            string `db:"url" json:"url"`
+}
+
+func (s *service) GetGeoRules(linkId int) ([]GeoRule, error) {
+	stmt := `SELECT id, link_id, country_iso_code, url
+	 FROM link_geo_rules
+	 WHERE link_id = $1
+	 ORDER BY country_iso_code`
+
+	rows, err := s.db.Query(stmt, linkId)
+	if err != nil {
+		log.Println("[GetGeoRules] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []GeoRule = make([]GeoRule, 0)
+
+	for rows.Next() {
+		var rule GeoRule
+		if err := rows.Scan(&rule.Id, &rule.LinkId, &rule.CountryISOCode, &rule.Url); err != nil {
+			log.Println("[GetGeoRules] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// ReplaceGeoRules swaps all geo rules of a link for the given ones in a
+// single transaction.
+func (s *service) ReplaceGeoRules(linkId int, rules []GeoRule) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println("[ReplaceGeoRules] Transaction start error: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM link_geo_rules WHERE link_id = $1`, linkId); err != nil {
+		log.Println("[ReplaceGeoRules] Delete statment error: ", err)
+		return err
+	}
+
+	if len(rules) > 0 {
+		valueStrings := make([]string, 0, len(rules))
+		valueArgs := make([]interface{}, 0, len(rules)*3)
+
+		for i, rule := range rules {
+			valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d)", i*3+1, i*3+2, i*3+3))
+			valueArgs = append(valueArgs, linkId, rule.CountryISOCode, rule.Url)
+		}
+
+		stmt := fmt.Sprintf(`INSERT INTO link_geo_rules (link_id, country_iso_code, url) VALUES %s`,
+			strings.Join(valueStrings, ","))
+
+		if _, err = tx.Exec(stmt, valueArgs...); err != nil {
+			log.Println("[ReplaceGeoRules] Insert statment error: ", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeviceRule sends visitors on a given OS and device type to an alternate
+// destination, typically an app deep link. FallbackUrl is used when the
+// destination cannot be opened, e.g. because the app is not installed.
+type DeviceRule struct {
+	Id          int    `db:"id" json:"id"`
+	LinkId      int    `db:"link_id" json:"-"`
+	Position    int    `db:"position" json:"position"`
+	OS          string `db:"os" json:"os"`
+	Device      string `db:"device" json:"device"`
+	Url         string `db:"url" json:"url"`
+	FallbackUrl string `db:"fallback_url" json:"fallback_url"`
+}
+
+// GetDeviceRules returns the device rules of a link in evaluation order.
+func (s *service) GetDeviceRules(linkId int) ([]DeviceRule, error) {
+	stmt := `SELECT id, link_id, position, os, device, url, fallback_url
+	 FROM link_device_rules
+	 WHERE link_id = $1
+	 ORDER BY position`
+
+	rows, err := s.db.Query(stmt, linkId)
+	if err != nil {
+		log.Println("[GetDeviceRules] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []DeviceRule = make([]DeviceRule, 0)
+
+	for rows.Next() {
+		var rule DeviceRule
+		if err := rows.Scan(
+			&rule.Id,
+			&rule.LinkId,
+			&rule.Position,
+			&rule.OS,
+			&rule.Device,
+			&rule.Url,
+			&rule.FallbackUrl,
+		); err != nil {
+			log.Println("[GetDeviceRules] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// ReplaceDeviceRules swaps all device rules of a link for the given ones,
+// their order in the slice becomes the evaluation order.
+func (s *service) ReplaceDeviceRules(linkId int, rules []DeviceRule) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println("[ReplaceDeviceRules] Transaction start error: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM link_device_rules WHERE link_id = $1`, linkId); err != nil {
+		log.Println("[ReplaceDeviceRules] Delete statment error: ", err)
+		return err
+	}
+
+	if len(rules) > 0 {
+		valueStrings := make([]string, 0, len(rules))
+		valueArgs := make([]interface{}, 0, len(rules)*6)
+
+		for i, rule := range rules {
+			valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)",
+				i*6+1, i*6+2, i*6+3, i*6+4, i*6+5, i*6+6))
+			valueArgs = append(valueArgs, linkId, i, rule.OS, rule.Device, rule.Url, rule.FallbackUrl)
+		}
+
+		stmt := fmt.Sprintf(`INSERT INTO link_device_rules (link_id, position, os, device, url, fallback_url) VALUES %s`,
+			strings.Join(valueStrings, ","))
+
+		if _, err = tx.Exec(stmt, valueArgs...); err != nil {
+			log.Println("[ReplaceDeviceRules] Insert statment error: ", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Variant is one destination of a weighted A/B split.
+type Variant struct {
+	Id     int    `db:"id" json:"id"`
+	LinkId int    `db:"link_id" json:"-"`
+	Url    string `db:"url" json:"url"`
+	Weight int    `db:"weight" json:"weight"`
+}
+
+func (s *service) GetVariants(linkId int) ([]Variant, error) {
+	stmt := `SELECT id, link_id, url, weight
+	 FROM link_variants
+	 WHERE link_id = $1
+	 ORDER BY id`
+
+	rows, err := s.db.Query(stmt, linkId)
+	if err != nil {
+		log.Println("[GetVariants] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []Variant = make([]Variant, 0)
+
+	for rows.Next() {
+		var variant Variant
+		if err := rows.Scan(&variant.Id, &variant.LinkId, &variant.Url, &variant.Weight); err != nil {
+			log.Println("[GetVariants] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		variants = append(variants, variant)
+	}
+
+	return variants, rows.Err()
+}
+
+// ReplaceVariants swaps the split destinations of a link for the given ones.
+func (s *service) ReplaceVariants(linkId int, variants []Variant) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println("[ReplaceVariants] Transaction start error: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM link_variants WHERE link_id = $1`, linkId); err != nil {
+		log.Println("[ReplaceVariants] Delete statment error: ", err)
+		return err
+	}
+
+	if len(variants) > 0 {
+		valueStrings := make([]string, 0, len(variants))
+		valueArgs := make([]interface{}, 0, len(variants)*3)
+
+		for i, variant := range variants {
+			valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d)", i*3+1, i*3+2, i*3+3))
+			valueArgs = append(valueArgs, linkId, variant.Url, variant.Weight)
+		}
+
+		stmt := fmt.Sprintf(`INSERT INTO link_variants (link_id, url, weight) VALUES %s`,
+			strings.Join(valueStrings, ","))
+
+		if _, err = tx.Exec(stmt, valueArgs...); err != nil {
+			log.Println("[ReplaceVariants] Insert statment error: ", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LinkRules are the device rules, geo rules and variants of a link, each in
+// evaluation order.
+type LinkRules struct {
+	DeviceRules []DeviceRule
+	GeoRules    []GeoRule
+	Variants    []Variant
+}
+
+// GetLinkRules loads everything deciding the destination of a visit in one
+// round trip, it runs on every redirect.
+func (s *service) GetLinkRules(linkId int) (*LinkRules, error) {
+	stmt := `SELECT 'device' AS kind, id, position, os::text, device::text, url, fallback_url, ''::text AS country_iso_code, 0 AS weight
+	 FROM link_device_rules
+	 WHERE link_id = $1
+	 UNION ALL
+	 SELECT 'geo', id, 0, '', '', url, '', country_iso_code::text, 0
+	 FROM link_geo_rules
+	 WHERE link_id = $1
+	 UNION ALL
+	 SELECT 'variant', id, 0, '', '', url, '', '', weight
+	 FROM link_variants
+	 WHERE link_id = $1
+	 ORDER BY kind, position, country_iso_code, id`
+
+	rows, err := s.db.Query(stmt, linkId)
+	if err != nil {
+		log.Println("[GetLinkRules] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := LinkRules{
+		DeviceRules: make([]DeviceRule, 0),
+		GeoRules:    make([]GeoRule, 0),
+		Variants:    make([]Variant, 0),
+	}
+
+	for rows.Next() {
+		var kind, os, device, url, fallbackUrl, countryISOCode string
+		var id, position, weight int
+		if err := rows.Scan(&kind, &id, &position, &os, &device, &url, &fallbackUrl, &countryISOCode, &weight); err != nil {
+			log.Println("[GetLinkRules] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		switch kind {
+		case "device":
+			rules.DeviceRules = append(rules.DeviceRules, DeviceRule{
+				Id:          id,
+				LinkId:      linkId,
+				Position:    position,
+				OS:          os,
+				Device:      device,
+				Url:         url,
+				FallbackUrl: fallbackUrl,
+			})
+		case "geo":
+			rules.GeoRules = append(rules.GeoRules, GeoRule{Id: id, LinkId: linkId, CountryISOCode: countryISOCode, Url: url})
+		case "variant":
+			rules.Variants = append(rules.Variants, Variant{Id: id, LinkId: linkId, Url: url, Weight: weight})
+		}
+	}
+
+	return &rules, rows.Err()
+}
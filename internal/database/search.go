@@ -0,0 +1,229 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// LinkSort is a column links can be listed by.
+type LinkSort string
+
+const (
+	SortByCreated LinkSort = "created_at"
+	SortByClicks  LinkSort = "clicks"
+)
+
+// LinkCursor points at the last link of a page, the next page starts right
+// after it. Only the field of the sort column is used.
+type LinkCursor struct {
+	CreatedAt  time.Time `json:"created_at,omitempty"`
+	ClickCount int       `json:"click_count,omitempty"`
+	Id         int       `json:"id"`
+}
+
+type ListLinksParams struct {
+	OwnerId   int
+	Sort      LinkSort
+	Ascending bool
+	// Tag only keeps links carrying this tag
+	Tag string
+	// Domain only keeps links pointing at this host or its subdomains
+	Domain string
+	// Query is a full text search over url, title and notes
+	Query          string
+	IncludeDeleted bool
+	Limit          int
+	After          *LinkCursor
+}
+
+type Tag struct {
+	Id        int    `db:"id" json:"id"`
+	Name      string `db:"name" json:"name"`
+	LinkCount int    `db:"link_count" json:"link_count"`
+}
+
+// ListLinks returns a page of the links of an account using keyset
+// pagination. The returned cursor is nil on the last page.
+func (s *service) ListLinks(params ListLinksParams) ([]LinkMap, *LinkCursor, error) {
+	args := []any{params.OwnerId}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"owner_id = $1"}
+
+	if !params.IncludeDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	if params.Tag != "" {
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+			WHERE lt.link_id = link_map.id AND t.name = %s
+		)`, arg(params.Tag)))
+	}
+
+	if params.Domain != "" {
+		domain := arg(strings.ToLower(params.Domain))
+		conditions = append(conditions, fmt.Sprintf("(dest_host = %s OR dest_host LIKE '%%.' || %s)", domain, domain))
+	}
+
+	if params.Query != "" {
+		conditions = append(conditions, fmt.Sprintf("search_vector @@ websearch_to_tsquery('simple', %s)", arg(params.Query)))
+	}
+
+	sortColumn, direction, comparison := "created_at", "DESC", "<"
+	if params.Sort == SortByClicks {
+		sortColumn = "click_count"
+	}
+	if params.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+
+	if params.After != nil {
+		var after any = params.After.CreatedAt
+		if params.Sort == SortByClicks {
+			after = params.After.ClickCount
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			sortColumn, comparison, arg(after), arg(params.After.Id)))
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	stmt := fmt.Sprintf(`SELECT %s,
+	 COALESCE((
+		SELECT json_agg(t.name ORDER BY t.name) 
+		FROM link_tags lt JOIN tags t ON t.id = lt.tag_id 
+		WHERE lt.link_id = link_map.id
+	 ), '[]')
+	 FROM link_map
+	 WHERE %s
+	 ORDER BY %s %s, id %s
+	 LIMIT %s`,
+		linkColumns,
+		strings.Join(conditions, " AND "),
+		sortColumn, direction, direction,
+		arg(params.Limit+1),
+	)
+
+	rows, err := s.db.Query(stmt, args...)
+	if err != nil {
+		log.Println("[ListLinks] error occured while querying", err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var links []LinkMap = make([]LinkMap, 0, params.Limit)
+
+	for rows.Next() {
+		var link LinkMap
+		var tags []byte
+		if err := scanLink(rows, &link, &tags); err != nil {
+			log.Println("[ListLinks] error occured while scanning to variable", err)
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(tags, &link.Tags); err != nil {
+			return nil, nil, err
+		}
+
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(links) <= params.Limit {
+		return links, nil, nil
+	}
+
+	links = links[:params.Limit]
+	last := links[len(links)-1]
+
+	return links, &LinkCursor{
+		CreatedAt:  last.CreatedAt,
+		ClickCount: last.ClickCount,
+		Id:         last.Id,
+	}, nil
+}
+
+// SetLinkTags replaces the tags of a link. Tags are created for the account
+// on first use.
+func (s *service) SetLinkTags(accountId int, linkId int, names []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println("[SetLinkTags] Transaction start error: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM link_tags WHERE link_id = $1`, linkId); err != nil {
+		log.Println("[SetLinkTags] Delete statment error: ", err)
+		return err
+	}
+
+	if len(names) > 0 {
+		placeholders := make([]string, 0, len(names))
+		valueStrings := make([]string, 0, len(names))
+		valueArgs := []interface{}{accountId}
+
+		for i, name := range names {
+			placeholders = append(placeholders, fmt.Sprintf("$%d", i+2))
+			valueStrings = append(valueStrings, fmt.Sprintf("($1, $%d)", i+2))
+			valueArgs = append(valueArgs, name)
+		}
+
+		stmt := fmt.Sprintf(`INSERT INTO tags (account_id, name) VALUES %s
+		 ON CONFLICT (account_id, name) DO NOTHING`, strings.Join(valueStrings, ","))
+
+		if _, err = tx.Exec(stmt, valueArgs...); err != nil {
+			log.Println("[SetLinkTags] Insert statment error: ", err)
+			return err
+		}
+
+		stmt = fmt.Sprintf(`INSERT INTO link_tags (link_id, tag_id)
+		 SELECT $%d, id FROM tags WHERE account_id = $1 AND name IN (%s)`,
+			len(valueArgs)+1, strings.Join(placeholders, ","))
+
+		if _, err = tx.Exec(stmt, append(valueArgs, linkId)...); err != nil {
+			log.Println("[SetLinkTags] Insert statment error: ", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTags lists the tags of an account with the number of links using them.
+func (s *service) GetTags(accountId int) ([]Tag, error) {
+	stmt := `SELECT t.id, t.name, COUNT(lt.link_id) AS link_count
+	 FROM tags t
+	 LEFT JOIN link_tags lt ON lt.tag_id = t.id
+	 WHERE t.account_id = $1
+	 GROUP BY t.id, t.name
+	 ORDER BY t.name`
+
+	rows, err := s.db.Query(stmt, accountId)
+	if err != nil {
+		log.Println("[GetTags] error occured while querying", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag = make([]Tag, 0)
+
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.Id, &tag.Name, &tag.LinkCount); err != nil {
+			log.Println("[GetTags] error occured while scanning to variable", err)
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
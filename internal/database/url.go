@@ -1,23 +1,86 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
-
-	"github.com/jackc/pgx/v5"
 )
 
 type LinkMap struct {
-	ShortCode string     `db:"short_code" json:"short_code"`
-	Url       string     `db:"url" json:"url"`
-	CreatedAt time.Time `db:"created_at" json:"created_at,omitempty"`
-	UpdatedAt time.Time `db:"updated_at" json:"updated_at,omitempty"`
+	// Id is the key other tables use, short codes are only unique per domain
+	Id       int `db:"id" json:"-"`
+	DomainId int `db:"domain_id" json:"-"`
+	// Domain is the hostname of the branded domain, empty for the default
+	// domain of FRONTEND_URL
+	Domain    string `db:"-" json:"domain,omitempty"`
+	ShortCode string `db:"short_code" json:"short_code"`
+	Url       string `db:"url" json:"url"`
+	// OriginalUrl is the shortener url the link was created with when Url
+	// is the final target it resolved to
+	OriginalUrl *string    `db:"original_url" json:"original_url,omitempty"`
+	Title       string     `db:"title" json:"title,omitempty"`
+	Notes       string     `db:"notes" json:"notes,omitempty"`
+	Tags        []string   `db:"-" json:"tags,omitempty"`
+	ExpiresAt   *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	MaxClicks   *int       `db:"max_clicks" json:"max_clicks,omitempty"`
+	ClickCount  int        `db:"click_count" json:"click_count"`
+	// RedirectStatus overrides the server wide redirect status for this link
+	RedirectStatus *int `db:"redirect_status" json:"redirect_status,omitempty"`
+	// Revision is the current entry of the link in link_revisions
+	Revision int `db:"revision" json:"revision"`
+	// PasswordHash is the bcrypt hash of the link password, empty when the
+	// link is not protected.
+	PasswordHash string `db:"password_hash" json:"-"`
+	// OwnerId is the account the link belongs to, nil for links created
+	// before accounts existed.
+	OwnerId    *int       `db:"owner_id" json:"-"`
+	DeletedAt  *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	DisabledAt *time.Time `db:"disabled_at" json:"disabled_at,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt  time.Time  `db:"updated_at" json:"updated_at,omitempty"`
+}
+
+// IsExpired reports whether the link has passed its expiry date or has
+// used up all of its allowed clicks.
+func (l *LinkMap) IsExpired(now time.Time) bool {
+	if l.ExpiresAt != nil && !now.Before(*l.ExpiresAt) {
+		return true
+	}
+
+	if l.MaxClicks != nil && l.ClickCount >= *l.MaxClicks {
+		return true
+	}
+
+	return false
 }
 
 func (s *service) InsertShortenedLink(link LinkMap) error {
-	stmt := `INSERT INTO link_map (short_code, url) VALUES ($1, $2)`
+	// The first revision is written in the same statement so every link
+	// has a complete history to roll back to
+	stmt := `WITH inserted AS (
+		INSERT INTO link_map (short_code, url, expires_at, max_clicks, password_hash, owner_id, redirect_status, title, notes, domain_id, original_url) 
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, NULLIF($8, ''), NULLIF($9, ''), $10, $11)
+		RETURNING id, revision, url, original_url
+	)
+	INSERT INTO link_revisions (link_id, revision, url, original_url)
+	SELECT id, revision, url, original_url FROM inserted`
 
-	_, err := s.db.Exec(stmt, link.ShortCode, link.Url)
+	_, err := s.db.Exec(stmt,
+		link.ShortCode,
+		link.Url,
+		link.ExpiresAt,
+		link.MaxClicks,
+		link.PasswordHash,
+		link.OwnerId,
+		link.RedirectStatus,
+		link.Title,
+		link.Notes,
+		link.DomainId,
+		link.OriginalUrl,
+	)
 
 	if err != nil {
 		log.Println("[InsertShortenedLink] Insert statment error: ", err)
@@ -27,38 +90,409 @@ func (s *service) InsertShortenedLink(link LinkMap) error {
 	return nil
 }
 
-func (s *service) GetLink(short_code string) (*LinkMap, error) {
-	stmt := `SELECT 
+// linkColumns are the link_map columns read by scanLink, in order.
+const linkColumns = `id,
+	 domain_id,
+	 COALESCE((SELECT hostname FROM domains WHERE domains.id = link_map.domain_id), ''),
 	 short_code,
 	 url, 
+	 original_url,
+	 COALESCE(title, ''),
+	 COALESCE(notes, ''),
+	 expires_at,
+	 max_clicks,
+	 click_count,
+	 revision,
+	 redirect_status,
+	 COALESCE(password_hash, ''),
+	 owner_id,
+	 deleted_at,
+	 disabled_at,
 	 created_at, 
-	 updated_at 
+	 updated_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanLink reads a row selected with linkColumns, extra columns selected
+// after them are scanned into extra.
+func scanLink(row rowScanner, link *LinkMap, extra ...any) error {
+	dest := []any{
+		&link.Id,
+		&link.DomainId,
+		&link.Domain,
+		&link.ShortCode,
+		&link.Url,
+		&link.OriginalUrl,
+		&link.Title,
+		&link.Notes,
+		&link.ExpiresAt,
+		&link.MaxClicks,
+		&link.ClickCount,
+		&link.Revision,
+		&link.RedirectStatus,
+		&link.PasswordHash,
+		&link.OwnerId,
+		&link.DeletedAt,
+		&link.DisabledAt,
+		&link.CreatedAt,
+		&link.UpdatedAt,
+	}
+
+	return row.Scan(append(dest, extra...)...)
+}
+
+// GetLink looks up a link on the domain with the given hostname, an empty
+// hostname stands for the default domain.
+func (s *service) GetLink(domain string, shortCode string) (*LinkMap, error) {
+	stmt := `SELECT ` + linkColumns + `
 	 FROM link_map 
-	 WHERE short_code = $1`
+	 WHERE short_code = $2
+	 AND domain_id = (SELECT id FROM domains WHERE hostname IS NOT DISTINCT FROM NULLIF($1, ''))`
 
-	row := s.db.QueryRow(stmt, short_code)
+	return s.getLink("[GetLink]", stmt, domain, shortCode)
+}
+
+// GetLinkForHost looks up the link a visitor of host asked for. Hosts that
+// are not a branded domain, like the one of FRONTEND_URL, use the default
+// domain.
+func (s *service) GetLinkForHost(host string, shortCode string) (*LinkMap, error) {
+	stmt := `SELECT ` + linkColumns + `
+	 FROM link_map 
+	 WHERE short_code = $2
+	 AND domain_id = COALESCE(
+		(SELECT id FROM domains WHERE hostname = $1),
+		(SELECT id FROM domains WHERE hostname IS NULL)
+	 )`
+
+	return s.getLink("[GetLinkForHost]", stmt, host, shortCode)
+}
+
+func (s *service) getLink(logTag string, stmt string, args ...any) (*LinkMap, error) {
+	row := s.db.QueryRow(stmt, args...)
 
 	var link LinkMap
 
-	err := row.Scan(&link.ShortCode, &link.Url, &link.CreatedAt, &link.UpdatedAt)
+	err := scanLink(row, &link)
 
-	if err != nil && err != pgx.ErrNoRows {
-		log.Println("[GetLink] error occured while copying data: ", err)
+	// Unknown links are returned as sql.ErrNoRows without logging them
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		log.Println(logTag, "error occured while copying data: ", err)
 		return nil, err
 	}
 
 	return &link, nil
 }
 
-func (s *service) UpdateShortenedLink(shortCode string, destUrl string) error {
-	stmt := `UPDATE link_map SET url=$1 WHERE short_code=$2`
+// LinkUpdate holds the changes made to a link by UpdateLink. Fields are only
+// written when the destination is set or their Set flag is true.
+type LinkUpdate struct {
+	// Url is the new destination, empty to keep the current one. OriginalUrl
+	// is the shortener url Url was resolved from, nil when Url was used as
+	// given.
+	Url         string
+	OriginalUrl *string
+
+	// SetLimits replaces the expiry date and click limit, a nil value
+	// removes the corresponding limit
+	SetLimits bool
+	ExpiresAt *time.Time
+	MaxClicks *int
 
-	_, err := s.db.Exec(stmt, destUrl, shortCode)
+	// SetPassword replaces the bcrypt hash protecting the link, an empty
+	// hash removes the password
+	SetPassword  bool
+	PasswordHash string
+
+	// SetRedirectStatus replaces the redirect status, nil falls back to the
+	// server default
+	SetRedirectStatus bool
+	RedirectStatus    *int
+
+	// SetDetails replaces the title and notes used to find a link again
+	SetDetails bool
+	Title      string
+	Notes      string
+}
 
+// UpdateLink applies update to a link in a single transaction. A new
+// destination is recorded as the next revision.
+func (s *service) UpdateLink(linkId int, update LinkUpdate) error {
+	tx, err := s.db.Begin()
 	if err != nil {
-		log.Println("[UpdateShortenedLink] Update statment error: ", err)
+		log.Println("[UpdateLink] Transaction start error: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if update.Url != "" {
+		stmt := `WITH updated AS (
+			UPDATE link_map 
+			SET url = $1, original_url = $3, revision = revision + 1, updated_at = now()
+			WHERE id = $2
+			RETURNING id, revision, url, original_url
+		)
+		INSERT INTO link_revisions (link_id, revision, url, original_url)
+		SELECT id, revision, url, original_url FROM updated`
+
+		if _, err = tx.Exec(stmt, update.Url, linkId, update.OriginalUrl); err != nil {
+			log.Println("[UpdateLink] Update destination statment error: ", err)
+			return err
+		}
+	}
+
+	if update.SetLimits {
+		stmt := `UPDATE link_map SET expires_at=$1, max_clicks=$2, updated_at=now() WHERE id = $3`
+
+		if _, err = tx.Exec(stmt, update.ExpiresAt, update.MaxClicks, linkId); err != nil {
+			log.Println("[UpdateLink] Update limits statment error: ", err)
+			return err
+		}
+	}
+
+	if update.SetPassword {
+		stmt := `UPDATE link_map SET password_hash=NULLIF($1, ''), updated_at=now() WHERE id = $2`
+
+		if _, err = tx.Exec(stmt, update.PasswordHash, linkId); err != nil {
+			log.Println("[UpdateLink] Update password statment error: ", err)
+			return err
+		}
+	}
+
+	if update.SetRedirectStatus {
+		stmt := `UPDATE link_map SET redirect_status=$1, updated_at=now() WHERE id = $2`
+
+		if _, err = tx.Exec(stmt, update.RedirectStatus, linkId); err != nil {
+			log.Println("[UpdateLink] Update redirect status statment error: ", err)
+			return err
+		}
+	}
+
+	if update.SetDetails {
+		stmt := `UPDATE link_map SET title=NULLIF($1, ''), notes=NULLIF($2, ''), updated_at=now() WHERE id = $3`
+
+		if _, err = tx.Exec(stmt, update.Title, update.Notes, linkId); err != nil {
+			log.Println("[UpdateLink] Update details statment error: ", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// IncrementClickCount atomically counts a visit against the link. It returns
+// false without counting anything when the link is expired, has no clicks
+// left or was deleted or disabled in the meantime, so concurrent visits can never exceed max_clicks.
+func (s *service) IncrementClickCount(linkId int) (bool, error) {
+	stmt := `UPDATE link_map 
+	 SET click_count = click_count + 1
+	 WHERE id = $1
+	 AND deleted_at IS NULL
+	 AND disabled_at IS NULL
+	 AND (expires_at IS NULL OR expires_at > now())
+	 AND (max_clicks IS NULL OR click_count < max_clicks)`
+
+	result, err := s.db.Exec(stmt, linkId)
+	if err != nil {
+		log.Println("[IncrementClickCount] Update statment error: ", err)
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// SetLinkDeleted soft deletes or restores a link. Deleted links keep their
+// short code and their clicks, they just stop resolving.
+func (s *service) SetLinkDeleted(linkId int, deleted bool) (bool, error) {
+	stmt := `UPDATE link_map 
+	 SET deleted_at = CASE WHEN $1 THEN COALESCE(deleted_at, now()) ELSE NULL END
+	 WHERE id = $2`
+
+	result, err := s.db.Exec(stmt, deleted, linkId)
+	if err != nil {
+		log.Println("[SetLinkDeleted] Update statment error: ", err)
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// SetLinkDisabled temporarily deactivates or reactivates a link.
+func (s *service) SetLinkDisabled(linkId int, disabled bool) (bool, error) {
+	stmt := `UPDATE link_map 
+	 SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, now()) ELSE NULL END
+	 WHERE id = $2`
+
+	result, err := s.db.Exec(stmt, disabled, linkId)
+	if err != nil {
+		log.Println("[SetLinkDisabled] Update statment error: ", err)
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// BulkLink is one row of a bulk insert. Generated links get their code from
+// the CodeSource and a new one when it collides, aliases are reported back as
+// not inserted instead. All links of a batch belong to the same domain.
+type BulkLink struct {
+	Link      LinkMap
+	Generated bool
+	Inserted  bool
+}
+
+const maxBulkAttempts = 5
+
+// CodeSource hands out the codes of generated links and is told about every
+// code that was already taken.
+type CodeSource interface {
+	Next() (string, error)
+	Collided()
+}
+
+// InsertShortenedLinks inserts many links in a single transaction using one
+// multi-row INSERT per attempt. Rows that could not be inserted keep
+// Inserted set to false, the batch as a whole only fails on database errors.
+func (s *service) InsertShortenedLinks(links []BulkLink, codes CodeSource) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println("[InsertShortenedLinks] Transaction start error: ", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Codes already used inside this batch, a multi-row insert cannot tell
+	// two rows with the same code apart
+	taken := make(map[string]bool, len(links))
+	for _, link := range links {
+		if !link.Generated {
+			taken[link.Link.ShortCode] = true
+		}
+	}
+
+	for attempt := 0; attempt < maxBulkAttempts; attempt++ {
+		var pending []*BulkLink
+		for i := range links {
+			link := &links[i]
+			if link.Inserted || (!link.Generated && attempt > 0) {
+				continue
+			}
+			if link.Generated {
+				for link.Link.ShortCode == "" || taken[link.Link.ShortCode] {
+					if link.Link.ShortCode != "" {
+						codes.Collided()
+					}
+					if link.Link.ShortCode, err = codes.Next(); err != nil {
+						return err
+					}
+				}
+				taken[link.Link.ShortCode] = true
+			}
+			pending = append(pending, link)
+		}
+
+		if len(pending) == 0 {
+			break
+		}
+
+		inserted, err := executeBulkInsert(tx, pending)
+		if err != nil {
+			log.Println("[InsertShortenedLinks] Insert statment error: ", err)
+			return err
+		}
+
+		for _, link := range pending {
+			link.Inserted = inserted[link.Link.ShortCode]
+			if link.Generated && !link.Inserted {
+				codes.Collided()
+				link.Link.ShortCode = ""
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Println("[InsertShortenedLinks] Commit error: ", err)
 		return err
 	}
 
 	return nil
 }
+
+// executeBulkInsert inserts the links with a single multi-value INSERT and
+// returns the short codes that did not collide with existing links.
+func executeBulkInsert(tx *sql.Tx, links []*BulkLink) (map[string]bool, error) {
+	valueStrings := make([]string, 0, len(links))
+	valueArgs := make([]interface{}, 0, len(links)*5)
+
+	for i, link := range links {
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5))
+
+		valueArgs = append(valueArgs,
+			link.Link.ShortCode,
+			link.Link.Url,
+			link.Link.OwnerId,
+			link.Link.DomainId,
+			link.Link.OriginalUrl,
+		)
+	}
+
+	stmt := fmt.Sprintf(`WITH inserted AS (
+		INSERT INTO link_map (short_code, url, owner_id, domain_id, original_url) 
+		VALUES %s
+		ON CONFLICT (domain_id, short_code) DO NOTHING
+		RETURNING id, short_code, revision, url, original_url
+	), revisions AS (
+		INSERT INTO link_revisions (link_id, revision, url, original_url)
+		SELECT id, revision, url, original_url FROM inserted
+	)
+	SELECT short_code FROM inserted`, strings.Join(valueStrings, ","))
+
+	rows, err := tx.Query(stmt, valueArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inserted := make(map[string]bool, len(links))
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return nil, err
+		}
+		inserted[shortCode] = true
+	}
+
+	return inserted, rows.Err()
+}
+
+// NextShortCodeId returns the next number of short_code_seq, the source of
+// counter based short codes.
+func (s *service) NextShortCodeId() (uint64, error) {
+	var id int64
+	if err := s.db.QueryRow(`SELECT nextval('short_code_seq')`).Scan(&id); err != nil {
+		log.Println("[NextShortCodeId] error occured while querying", err)
+		return 0, err
+	}
+
+	return uint64(id), nil
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"time"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindInt
+	kindFloat
+	kindBool
+	kindTime
+)
+
+// column is one field of the export. value returns a string, int64,
+// float64, bool or time.Time matching kind, nil for an empty nullable field.
+type column struct {
+	name     string
+	kind     kind
+	nullable bool
+	value    func(c *database.ExportedClick) any
+}
+
+// columns are all fields of an export in the order they are written.
+var columns = []column{
+	{"id", kindInt, false, func(c *database.ExportedClick) any { return int64(c.Id) }},
+	{"clicked_at", kindTime, false, func(c *database.ExportedClick) any { return c.ClickedAt }},
+	{"domain", kindString, false, func(c *database.ExportedClick) any { return c.Domain }},
+	{"short_code", kindString, false, func(c *database.ExportedClick) any { return c.ShortCode }},
+	{"ip_addr", kindString, false, func(c *database.ExportedClick) any { return c.IpAddr }},
+	{"user_agent", kindString, false, func(c *database.ExportedClick) any { return c.UserAgent }},
+	{"browser", kindString, false, func(c *database.ExportedClick) any { return c.Browser }},
+	{"browser_version", kindString, false, func(c *database.ExportedClick) any { return c.BrowserVersion }},
+	{"os", kindString, false, func(c *database.ExportedClick) any { return c.OS }},
+	{"os_version", kindString, false, func(c *database.ExportedClick) any { return c.OSVersion }},
+	{"device", kindString, false, func(c *database.ExportedClick) any { return c.Device }},
+	{"device_type", kindString, false, func(c *database.ExportedClick) any { return c.DeviceType }},
+	{"is_bot", kindBool, false, func(c *database.ExportedClick) any { return c.IsBot }},
+	{"referrer", kindString, false, func(c *database.ExportedClick) any { return c.Referrer }},
+	{"raw_referrer", kindString, false, func(c *database.ExportedClick) any { return c.RawReferrer }},
+	{"referrer_domain", kindString, false, func(c *database.ExportedClick) any { return c.ReferrerDomain }},
+	{"referrer_category", kindString, false, func(c *database.ExportedClick) any { return c.ReferrerCategory }},
+	{"country", kindString, false, func(c *database.ExportedClick) any { return c.Country }},
+	{"country_iso_code", kindString, false, func(c *database.ExportedClick) any { return c.CountryISOCode }},
+	{"subdivision", kindString, false, func(c *database.ExportedClick) any { return c.Subdivision }},
+	{"subdivision_iso_code", kindString, false, func(c *database.ExportedClick) any { return c.SubdivisionISOCode }},
+	{"city", kindString, false, func(c *database.ExportedClick) any { return c.City }},
+	{"latitude", kindFloat, true, func(c *database.ExportedClick) any { return floatOrNil(c.Latitude) }},
+	{"longitude", kindFloat, true, func(c *database.ExportedClick) any { return floatOrNil(c.Longitude) }},
+	{"source", kindString, false, func(c *database.ExportedClick) any { return c.Source }},
+	{"revision", kindInt, false, func(c *database.ExportedClick) any { return int64(c.Revision) }},
+	{"variant_id", kindInt, true, func(c *database.ExportedClick) any { return intOrNil(c.VariantId) }},
+	{"geo_rule_id", kindInt, true, func(c *database.ExportedClick) any { return intOrNil(c.GeoRuleId) }},
+	{"device_rule_id", kindInt, true, func(c *database.ExportedClick) any { return intOrNil(c.DeviceRuleId) }},
+}
+
+// FieldNames lists the names of all exported fields.
+func FieldNames() []string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.name
+	}
+
+	return names
+}
+
+func lookupColumn(name string) (column, bool) {
+	for _, col := range columns {
+		if col.name == name {
+			return col, true
+		}
+	}
+
+	return column{}, false
+}
+
+func intOrNil(v *int) any {
+	if v == nil {
+		return nil
+	}
+
+	return int64(*v)
+}
+
+func floatOrNil(v *float64) any {
+	if v == nil {
+		return nil
+	}
+
+	return *v
+}
+
+// utc writes times the same way in every format.
+func utc(v any) any {
+	if t, ok := v.(time.Time); ok {
+		return t.UTC()
+	}
+
+	return v
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+type csvEncoder struct {
+	writer *csv.Writer
+	record []string
+}
+
+// newCSVEncoder writes the header right away. Empty values are written as
+// empty fields.
+func newCSVEncoder(output io.Writer, columns []column) (*csvEncoder, error) {
+	e := &csvEncoder{
+		writer: csv.NewWriter(output),
+		record: make([]string, len(columns)),
+	}
+
+	for i, col := range columns {
+		e.record[i] = col.name
+	}
+	if err := e.writer.Write(e.record); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *csvEncoder) write(values []any) error {
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil:
+			e.record[i] = ""
+		case string:
+			e.record[i] = v
+		case int64:
+			e.record[i] = strconv.FormatInt(v, 10)
+		case float64:
+			e.record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			e.record[i] = strconv.FormatBool(v)
+		case time.Time:
+			e.record[i] = v.Format(time.RFC3339Nano)
+		}
+	}
+
+	// csv.Writer buffers, errors show up on a later write or in close
+	if err := e.writer.Write(e.record); err != nil {
+		return err
+	}
+
+	return e.writer.Error()
+}
+
+func (e *csvEncoder) close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type ndjsonEncoder struct {
+	writer *bufio.Writer
+	// keys are the quoted column names followed by a colon
+	keys [][]byte
+	line []byte
+}
+
+// newNDJSONEncoder writes one object per line with the fields in column
+// order, empty values are null.
+func newNDJSONEncoder(output io.Writer, columns []column) *ndjsonEncoder {
+	e := &ndjsonEncoder{writer: bufio.NewWriter(output)}
+
+	for _, col := range columns {
+		key, _ := json.Marshal(col.name)
+		e.keys = append(e.keys, append(key, ':'))
+	}
+
+	return e
+}
+
+func (e *ndjsonEncoder) write(values []any) error {
+	e.line = append(e.line[:0], '{')
+
+	for i, value := range values {
+		if i > 0 {
+			e.line = append(e.line, ',')
+		}
+		e.line = append(e.line, e.keys[i]...)
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		e.line = append(e.line, encoded...)
+	}
+	e.line = append(e.line, '}', '\n')
+
+	_, err := e.writer.Write(e.line)
+	return err
+}
+
+func (e *ndjsonEncoder) close() error {
+	return e.writer.Flush()
+}
@@ -0,0 +1,114 @@
+// Package export writes raw clicks as CSV, NDJSON or Parquet. Rows are
+// written one at a time as they come from the database, so exports of any
+// size only hold a row group (Parquet) or a buffer (CSV, NDJSON) in memory.
+package export
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+type Format string
+
+const (
+	CSV     Format = "csv"
+	NDJSON  Format = "ndjson"
+	Parquet Format = "parquet"
+)
+
+// ParseFormat accepts csv, ndjson and parquet, csv when raw is empty.
+func ParseFormat(raw string) (Format, error) {
+	switch format := Format(raw); format {
+	case "":
+		return CSV, nil
+	case CSV, NDJSON, Parquet:
+		return format, nil
+	default:
+		return "", fmt.Errorf("format must be one of csv, ndjson or parquet")
+	}
+}
+
+// ContentType is the media type of an export in format f.
+func (f Format) ContentType() string {
+	switch f {
+	case NDJSON:
+		return "application/x-ndjson"
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// encoder writes the rows of one format, values are in the order of the
+// columns it was created with.
+type encoder interface {
+	write(values []any) error
+	close() error
+}
+
+// Writer writes the clicks of one export. Close must be called to complete
+// the file.
+type Writer struct {
+	columns []column
+	// pseudonymize is set for the columns replaced by pseudonyms
+	pseudonymize []bool
+	key          []byte
+	values       []any
+	encoder      encoder
+}
+
+// NewWriter starts an export in format to output, leaving out or
+// pseudonymizing fields as privacy says.
+func NewWriter(output io.Writer, format Format, privacy *Privacy) (*Writer, error) {
+	key, err := privacy.exportKey()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{key: key}
+	for _, col := range columns {
+		if privacy.drop[col.name] {
+			continue
+		}
+		w.columns = append(w.columns, col)
+		w.pseudonymize = append(w.pseudonymize, privacy.pseudonymize[col.name])
+	}
+	w.values = make([]any, len(w.columns))
+
+	switch format {
+	case CSV:
+		w.encoder, err = newCSVEncoder(output, w.columns)
+	case NDJSON:
+		w.encoder = newNDJSONEncoder(output, w.columns)
+	case Parquet:
+		w.encoder = newParquetEncoder(output, w.columns)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Write adds one click to the export.
+func (w *Writer) Write(click *database.ExportedClick) error {
+	for i, col := range w.columns {
+		value := col.value(click)
+		if w.pseudonymize[i] {
+			value = pseudonym(w.key, value.(string))
+		}
+		w.values[i] = utc(value)
+	}
+
+	return w.encoder.write(w.values)
+}
+
+// Close flushes the remaining rows and, for Parquet, writes the footer.
+func (w *Writer) Close() error {
+	return w.encoder.close()
+}
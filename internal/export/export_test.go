@@ -0,0 +1,184 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+func testClicks() []database.ExportedClick {
+	variant := 7
+	latitude, longitude := 48.1, 11.6
+
+	first := database.ExportedClick{ShortCode: "abc123"}
+	first.Id = 1
+	first.ClickedAt = time.Date(2026, 10, 1, 12, 30, 0, 0, time.UTC)
+	first.IpAddr = "hashed-ip"
+	first.UserAgent = "Mozilla/5.0"
+	first.Referrer = "google.com/search"
+	first.RawReferrer = "https://www.google.com/search?q=secret"
+	first.City = "Munich"
+	first.Latitude, first.Longitude = &latitude, &longitude
+	first.VariantId = &variant
+
+	second := database.ExportedClick{ShortCode: "abc123", Domain: "go.example.com"}
+	second.Id = 2
+	second.ClickedAt = time.Date(2026, 10, 2, 8, 0, 0, 0, time.UTC)
+	second.IpAddr = "hashed-ip"
+	second.IsBot = true
+
+	return []database.ExportedClick{first, second}
+}
+
+func writeExport(t *testing.T, format Format, privacy *Privacy) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, format, privacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, click := range testClicks() {
+		if err := w.Write(&click); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	privacy, err := NewPrivacy([]string{"raw_referrer", "user_agent"}, []string{"ip_addr"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(writeExport(t, CSV, privacy))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records; want a header and 2 rows", len(records))
+	}
+
+	header := strings.Join(records[0], ",")
+	if strings.Contains(header, "raw_referrer") || strings.Contains(header, "user_agent") {
+		t.Errorf("dropped fields are in the header: %s", header)
+	}
+	if len(records[0]) != len(columns)-2 {
+		t.Errorf("got %d columns; want %d", len(records[0]), len(columns)-2)
+	}
+
+	row := make(map[string]string)
+	for i, name := range records[0] {
+		row[name] = records[1][i]
+	}
+	if row["clicked_at"] != "2026-10-01T12:30:00Z" || row["latitude"] != "48.1" || row["variant_id"] != "7" || row["geo_rule_id"] != "" {
+		t.Errorf("unexpected row %v", row)
+	}
+	if row["ip_addr"] == "hashed-ip" || len(row["ip_addr"]) != pseudonymLength {
+		t.Errorf("ip_addr is not pseudonymized: %q", row["ip_addr"])
+	}
+	if strings.Contains(string(writeExport(t, CSV, privacy)), "secret") {
+		t.Error("export contains the dropped raw referrer")
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	privacy, err := NewPrivacy(nil, []string{"ip_addr"}, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(writeExport(t, NDJSON, privacy)))
+	for scanner.Scan() {
+		var row map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows; want 2", len(rows))
+	}
+	if rows[1]["latitude"] != nil || rows[1]["is_bot"] != true || rows[1]["domain"] != "go.example.com" {
+		t.Errorf("unexpected row %v", rows[1])
+	}
+	// The same visitor keeps the same pseudonym, and a key makes it stable
+	// across exports
+	if rows[0]["ip_addr"] != rows[1]["ip_addr"] || rows[0]["ip_addr"] != pseudonym([]byte("key"), "hashed-ip") {
+		t.Errorf("got pseudonyms %v and %v", rows[0]["ip_addr"], rows[1]["ip_addr"])
+	}
+}
+
+func TestParquet(t *testing.T) {
+	privacy, err := NewPrivacy([]string{"raw_referrer"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := writeExport(t, Parquet, privacy)
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.NumRows() != 2 {
+		t.Fatalf("got %d rows; want 2", file.NumRows())
+	}
+	if _, ok := file.Schema().Lookup("raw_referrer"); ok {
+		t.Error("dropped field is in the schema")
+	}
+
+	type row struct {
+		Id        int64     `parquet:"id"`
+		ClickedAt time.Time `parquet:"clicked_at,timestamp(microsecond)"`
+		ShortCode string    `parquet:"short_code"`
+		City      string    `parquet:"city"`
+		Latitude  *float64  `parquet:"latitude,optional"`
+		VariantId *int64    `parquet:"variant_id,optional"`
+		IsBot     bool      `parquet:"is_bot"`
+	}
+	rows, err := parquet.Read[row](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := rows[0], rows[1]
+	if first.Id != 1 || !first.ClickedAt.Equal(testClicks()[0].ClickedAt) || first.City != "Munich" ||
+		first.Latitude == nil || *first.Latitude != 48.1 || first.VariantId == nil || *first.VariantId != 7 {
+		t.Errorf("unexpected first row %+v", first)
+	}
+	if second.Id != 2 || second.Latitude != nil || second.VariantId != nil || !second.IsBot {
+		t.Errorf("unexpected second row %+v", second)
+	}
+}
+
+func TestNewPrivacy(t *testing.T) {
+	if _, err := NewPrivacy([]string{"password"}, nil, nil); err == nil {
+		t.Error("expected an unknown field to be rejected")
+	}
+	if _, err := NewPrivacy(nil, []string{"latitude"}, nil); err == nil {
+		t.Error("expected a number to be rejected for pseudonymization")
+	}
+
+	privacy, _ := NewPrivacy([]string{"raw_referrer"}, []string{"ip_addr"}, nil)
+	stricter, err := privacy.Drop([]string{"city"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stricter.drop["raw_referrer"] || !stricter.drop["city"] || !stricter.pseudonymize["ip_addr"] || privacy.drop["city"] {
+		t.Errorf("unexpected merged settings %+v", stricter)
+	}
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRowGroupSize bounds the rows buffered before a row group is written
+// out.
+const parquetRowGroupSize = 10000
+
+type parquetEncoder struct {
+	writer *parquet.Writer
+	// leaves are the parquet columns of the export columns, in their order
+	leaves []parquet.LeafColumn
+	row    parquet.Row
+}
+
+// newParquetEncoder builds the schema from the columns. Parquet orders the
+// fields of a group by name, values are placed by their leaf column index.
+func newParquetEncoder(output io.Writer, columns []column) *parquetEncoder {
+	group := make(parquet.Group, len(columns))
+	for _, col := range columns {
+		var node parquet.Node
+		switch col.kind {
+		case kindString:
+			node = parquet.String()
+		case kindInt:
+			node = parquet.Int(64)
+		case kindFloat:
+			node = parquet.Leaf(parquet.DoubleType)
+		case kindBool:
+			node = parquet.Leaf(parquet.BooleanType)
+		case kindTime:
+			node = parquet.Timestamp(parquet.Microsecond)
+		}
+		if col.nullable {
+			node = parquet.Optional(node)
+		}
+		group[col.name] = node
+	}
+
+	schema := parquet.NewSchema("clicks", group)
+	e := &parquetEncoder{
+		writer: parquet.NewWriter(output, schema, parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		row:    make(parquet.Row, len(columns)),
+	}
+	for _, col := range columns {
+		leaf, _ := schema.Lookup(col.name)
+		e.leaves = append(e.leaves, leaf)
+	}
+
+	return e
+}
+
+func (e *parquetEncoder) write(values []any) error {
+	for i, value := range values {
+		leaf := e.leaves[i]
+
+		var v parquet.Value
+		switch value := value.(type) {
+		case nil:
+			e.row[leaf.ColumnIndex] = parquet.NullValue().Level(0, 0, leaf.ColumnIndex)
+			continue
+		case string:
+			v = parquet.ByteArrayValue([]byte(value))
+		case int64:
+			v = parquet.Int64Value(value)
+		case float64:
+			v = parquet.DoubleValue(value)
+		case bool:
+			v = parquet.BooleanValue(value)
+		case time.Time:
+			v = parquet.Int64Value(value.UnixMicro())
+		}
+		e.row[leaf.ColumnIndex] = v.Level(0, leaf.MaxDefinitionLevel, leaf.ColumnIndex)
+	}
+
+	_, err := e.writer.WriteRows([]parquet.Row{e.row})
+	return err
+}
+
+func (e *parquetEncoder) close() error {
+	return e.writer.Close()
+}
@@ -0,0 +1,128 @@
+package export
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PrivacyFromEnv reads which fields exports leave out (EXPORT_DROP_FIELDS,
+// raw_referrer by default) and pseudonymize (EXPORT_PSEUDONYMIZE_FIELDS,
+// ip_addr by default), both comma separated. Set them to an empty value to
+// export the fields as they are. Pseudonyms only stay the same across
+// exports with an EXPORT_PSEUDONYM_KEY.
+func PrivacyFromEnv() (*Privacy, error) {
+	drop, ok := os.LookupEnv("EXPORT_DROP_FIELDS")
+	if !ok {
+		drop = "raw_referrer"
+	}
+	pseudonymize, ok := os.LookupEnv("EXPORT_PSEUDONYMIZE_FIELDS")
+	if !ok {
+		pseudonymize = "ip_addr"
+	}
+
+	return NewPrivacy(strings.Split(drop, ","), strings.Split(pseudonymize, ","), []byte(os.Getenv("EXPORT_PSEUDONYM_KEY")))
+}
+
+// pseudonymLength is the number of hex characters kept of a pseudonym, 64
+// bits are plenty to tell visitors apart within an export.
+const pseudonymLength = 16
+
+// Privacy decides which fields leave the database. Dropped fields are left
+// out of the export entirely, pseudonymized ones are replaced by a keyed
+// hash so equal values stay equal without revealing them.
+type Privacy struct {
+	drop         map[string]bool
+	pseudonymize map[string]bool
+	// key is the HMAC key of pseudonyms, a random one is used per export
+	// when it is empty
+	key []byte
+}
+
+// NewPrivacy validates the field names, only text fields can be
+// pseudonymized. With a key, pseudonyms are the same across exports.
+func NewPrivacy(drop []string, pseudonymize []string, key []byte) (*Privacy, error) {
+	p := &Privacy{
+		drop:         make(map[string]bool),
+		pseudonymize: make(map[string]bool),
+		key:          key,
+	}
+
+	for _, name := range drop {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if _, ok := lookupColumn(name); !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		p.drop[name] = true
+	}
+
+	for _, name := range pseudonymize {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		col, ok := lookupColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		if col.kind != kindString {
+			return nil, fmt.Errorf("field %q is not text and cannot be pseudonymized", name)
+		}
+		p.pseudonymize[name] = true
+	}
+
+	return p, nil
+}
+
+// Drop returns a copy of p that drops the given fields as well.
+func (p *Privacy) Drop(names []string) (*Privacy, error) {
+	extra, err := NewPrivacy(names, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &Privacy{
+		drop:         make(map[string]bool),
+		pseudonymize: p.pseudonymize,
+		key:          p.key,
+	}
+	for name := range p.drop {
+		merged.drop[name] = true
+	}
+	for name := range extra.drop {
+		merged.drop[name] = true
+	}
+
+	return merged, nil
+}
+
+// exportKey returns the pseudonym key of one export.
+func (p *Privacy) exportKey() ([]byte, error) {
+	if len(p.key) > 0 {
+		return p.key, nil
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// pseudonym hashes value with key, empty values stay empty.
+func pseudonym(key []byte, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))[:pseudonymLength]
+}
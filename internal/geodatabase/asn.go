@@ -0,0 +1,41 @@
+package geodatabase
+
+import (
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// ASNService looks up the autonomous system an IP belongs to, it is backed
+// by a GeoLite2-ASN database.
+type ASNService interface {
+	GetASNByIP(ipAddr net.IP) (uint, error)
+	Close() error
+}
+
+type asnService struct {
+	db *geoip2.Reader
+}
+
+// NewASN opens the ASN database at path.
+func NewASN(path string) (ASNService, error) {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &asnService{db: db}, nil
+}
+
+func (s *asnService) GetASNByIP(ipAddr net.IP) (uint, error) {
+	record, err := s.db.ASN(ipAddr)
+	if err != nil {
+		return 0, err
+	}
+
+	return record.AutonomousSystemNumber, nil
+}
+
+func (s *asnService) Close() error {
+	return s.db.Close()
+}
@@ -3,21 +3,28 @@ package geodatabase
 import (
 	"log"
 	"net"
+	"os"
 
 	"github.com/oschwald/geoip2-golang"
 )
 
 type Service interface {
 	GetCountryByIP(ipAddr net.IP) (*geoip2.Country, error)
+	// GetCityByIP returns nil without an error when no City database is
+	// installed
+	GetCityByIP(ipAddr net.IP) (*geoip2.City, error)
 	Close() error
 }
 
 type service struct {
 	db *geoip2.Reader
+	// city is nil unless GeoLite2-City.mmdb is present
+	city *geoip2.Reader
 }
 
 var (
 	geodbPath  = "./data/GeoLite2-Country.mmdb"
+	cityPath   = "./data/GeoLite2-City.mmdb"
 	dbInstance *service
 )
 
@@ -34,6 +41,13 @@ func New() Service {
 		db: geodbInstance,
 	}
 
+	// The City database is optional, without it clicks only get a country
+	if _, err := os.Stat(cityPath); err == nil {
+		if dbInstance.city, err = geoip2.Open(cityPath); err != nil {
+			log.Println("GeoLite2-City could not be opened, recording countries only: ", err)
+		}
+	}
+
 	return dbInstance
 }
 
@@ -43,5 +57,10 @@ func (s *service) Close() error {
 	if err := s.db.Close(); err != nil {
 		log.Fatal(err)
 	}
+	if s.city != nil {
+		if err := s.city.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
 	return nil
 }
@@ -17,3 +17,18 @@ func (s *service) GetCountryByIP(ipAddr net.IP) (*geoip2.Country, error) {
 
 	return record, nil
 }
+
+func (s *service) GetCityByIP(ipAddr net.IP) (*geoip2.City, error) {
+	if s.city == nil {
+		return nil, nil
+	}
+
+	record, err := s.city.City(ipAddr)
+
+	if err != nil {
+		log.Println("[GetCityByIP] Failed to get city for ip", ipAddr, err)
+		return nil, err
+	}
+
+	return record, nil
+}
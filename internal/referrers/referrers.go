@@ -0,0 +1,108 @@
+// Package referrers normalizes the referrers of clicks and sorts them into
+// categories like search or social.
+package referrers
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Category is the kind of source a click came from.
+type Category string
+
+const (
+	Search Category = "search"
+	Social Category = "social"
+	Email  Category = "email"
+	// Direct clicks came without a referrer
+	Direct Category = "direct"
+	Other  Category = "other"
+)
+
+const (
+	// DirectReferrer is stored for clicks without a referrer
+	DirectReferrer = "direct"
+	// UnknownReferrer is stored for referrers that are not a url
+	UnknownReferrer = "unknown"
+)
+
+// maxPathLength cuts overly long referrer paths, they are not useful for
+// analytics anyway.
+const maxPathLength = 200
+
+// Referrer is a classified referrer.
+type Referrer struct {
+	// Raw is the referrer as received
+	Raw string
+	// Referrer is the registrable domain followed by the path, without
+	// query string and fragment
+	Referrer string
+	// Domain is the registrable domain, e.g. google.co.uk for
+	// https://www.google.co.uk/search?q=...
+	Domain   string
+	Category Category
+}
+
+// Normalize splits a referrer url into its host, registrable domain and
+// path. Query strings and fragments are dropped since they can carry
+// personal data. Referrers of apps (android-app://com.google.android.gm/)
+// keep the package name as domain and have no path. ok is false when raw is
+// not a url.
+func Normalize(raw string) (host string, domain string, path string, ok bool) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	uri, err := url.Parse(raw)
+	if err != nil {
+		return "", "", "", false
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(uri.Hostname()), ".")
+	if host == "" {
+		return "", "", "", false
+	}
+
+	scheme := strings.ToLower(uri.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return host, host, "", true
+	}
+
+	domain = host
+	if net.ParseIP(host) == nil && strings.Contains(host, ".") {
+		if registrable, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+			domain = registrable
+		}
+	}
+
+	path = strings.TrimRight(uri.EscapedPath(), "/")
+	if len(path) > maxPathLength {
+		path = path[:maxPathLength]
+	}
+
+	return host, domain, path, true
+}
+
+// Classify normalizes raw and looks up its category in the rules. An empty
+// referrer is a direct click.
+func (r *Rules) Classify(raw string) Referrer {
+	if strings.TrimSpace(raw) == "" {
+		return Referrer{Referrer: DirectReferrer, Domain: DirectReferrer, Category: Direct}
+	}
+
+	host, domain, path, ok := Normalize(raw)
+	if !ok {
+		return Referrer{Raw: raw, Referrer: UnknownReferrer, Domain: UnknownReferrer, Category: Other}
+	}
+
+	return Referrer{
+		Raw:      raw,
+		Referrer: domain + path,
+		Domain:   domain,
+		Category: r.category(host, domain),
+	}
+}
@@ -0,0 +1,76 @@
+package referrers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	rules := DefaultRules()
+
+	cases := []struct {
+		raw      string
+		referrer string
+		domain   string
+		category Category
+	}{
+		{"", "direct", "direct", Direct},
+		{"https://www.google.com/", "google.com", "google.com", Search},
+		{"https://google.com", "google.com", "google.com", Search},
+		{"https://www.google.co.uk/search?q=my+name&client=firefox", "google.co.uk/search", "google.co.uk", Search},
+		{"https://mail.google.com/mail/u/0/#inbox", "google.com/mail/u/0", "google.com", Email},
+		{"android-app://com.google.android.gm/", "com.google.android.gm", "com.google.android.gm", Email},
+		{"https://t.co/abc123", "t.co/abc123", "t.co", Social},
+		{"https://old.reddit.com/r/golang/comments/xyz/?utm_source=share", "reddit.com/r/golang/comments/xyz", "reddit.com", Social},
+		{"news.ycombinator.com/item?id=1", "ycombinator.com/item", "ycombinator.com", Social},
+		{"https://blog.example.org:8443/post/", "example.org/post", "example.org", Other},
+		{"http://192.0.2.10/page", "192.0.2.10/page", "192.0.2.10", Other},
+		{"https://", "unknown", "unknown", Other},
+	}
+
+	for _, c := range cases {
+		got := rules.Classify(c.raw)
+		if got.Referrer != c.referrer || got.Domain != c.domain || got.Category != c.category {
+			t.Errorf("Classify(%q) = %+v; want %s, %s, %s", c.raw, got, c.referrer, c.domain, c.category)
+		}
+		if c.raw != "" && got.Raw != c.raw {
+			t.Errorf("Classify(%q) lost the raw referrer: %q", c.raw, got.Raw)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader(`
+		# comment
+		search example.*
+		email mail.example.com   # more specific than example.*
+		social club.example.net
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]Category{
+		"https://www.example.de/q":      Search,
+		"https://mail.example.com/":     Email,
+		"https://www.club.example.net/": Social,
+		"https://other.net/":            Other,
+	}
+	for raw, want := range cases {
+		if got := rules.Classify(raw).Category; got != want {
+			t.Errorf("Classify(%q) = %s; want %s", raw, got, want)
+		}
+	}
+
+	invalid := []string{
+		"search",
+		"ads example.com",
+		"search example.com/path",
+		"search .*",
+	}
+	for _, text := range invalid {
+		if _, err := ParseRules(strings.NewReader(text)); err == nil {
+			t.Errorf("expected %q to be rejected", text)
+		}
+	}
+}
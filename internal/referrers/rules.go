@@ -0,0 +1,123 @@
+package referrers
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+//go:embed rules.txt
+var defaultRules string
+
+// Rules map referrer hosts to categories.
+type Rules struct {
+	// hosts match the host and its subdomains, longest first so that
+	// mail.google.com wins over google.com
+	hosts []rule
+	// names match the registrable domain under any public suffix, e.g.
+	// "google" for "google.*"
+	names map[string]Category
+}
+
+type rule struct {
+	host     string
+	category Category
+}
+
+// DefaultRules returns the rules maintained in rules.txt.
+func DefaultRules() *Rules {
+	rules, err := ParseRules(strings.NewReader(defaultRules))
+	if err != nil {
+		panic("referrers: invalid rules.txt: " + err.Error())
+	}
+
+	return rules
+}
+
+// ParseRules reads one "<category> <host>" entry per line, the category is
+// search, social or email. A host matches itself and its subdomains, a host
+// ending in ".*" like "google.*" matches that name under every public suffix.
+// Blank lines and everything after a '#' are ignored.
+func ParseRules(reader io.Reader) (*Rules, error) {
+	rules := &Rules{names: make(map[string]Category)}
+
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<category> <host>\"", line)
+		}
+
+		category := Category(strings.ToLower(fields[0]))
+		switch category {
+		case Search, Social, Email:
+		default:
+			return nil, fmt.Errorf("line %d: unknown category %q", line, fields[0])
+		}
+
+		host := strings.TrimSuffix(strings.ToLower(fields[1]), ".")
+		if name, ok := strings.CutSuffix(host, ".*"); ok {
+			if name == "" || strings.ContainsAny(name, "./:*") {
+				return nil, fmt.Errorf("line %d: invalid name %q", line, fields[1])
+			}
+			rules.names[name] = category
+			continue
+		}
+		if host == "" || strings.ContainsAny(host, "/:*") {
+			return nil, fmt.Errorf("line %d: invalid host %q", line, fields[1])
+		}
+		rules.hosts = append(rules.hosts, rule{host: host, category: category})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(rules.hosts, func(i, j int) bool {
+		return len(rules.hosts[i].host) > len(rules.hosts[j].host)
+	})
+
+	return rules, nil
+}
+
+// LoadRules parses the rules file at path.
+func LoadRules(path string) (*Rules, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules, err := ParseRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return rules, nil
+}
+
+func (r *Rules) category(host string, domain string) Category {
+	for _, rule := range r.hosts {
+		if host == rule.host || strings.HasSuffix(host, "."+rule.host) {
+			return rule.category
+		}
+	}
+
+	if suffix, _ := publicsuffix.PublicSuffix(domain); suffix != domain {
+		if category, ok := r.names[strings.TrimSuffix(domain, "."+suffix)]; ok {
+			return category
+		}
+	}
+
+	return Other
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	// Time zones are looked up by name, the slim production image has no
+	// zoneinfo of its own
+	_ "time/tzdata"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+// maxTimelineBuckets keeps hourly timelines over long ranges from getting out
+// of hand, it is a bit more than 13 years of days
+const maxTimelineBuckets = 5000
+
+// timelineIntervals are the bucket sizes of timelines with the approximate
+// length used to count buckets
+var timelineIntervals = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+const dateLayout = "2006-01-02"
+
+const (
+	// defaultSummaryTop is the number of entries per breakdown in summaries
+	defaultSummaryTop = 10
+	maxTop            = 100
+)
+
+// parseClickRange reads the from, to, interval, tz, top and include_bots
+// query parameters of the analytics endpoints. from and to are RFC 3339 times
+// or dates in tz, a date given as to includes the whole day. The returned
+// message is empty when the parameters are valid.
+func parseClickRange(query url.Values) (database.ClickRange, string) {
+	rng := database.ClickRange{
+		Interval: query.Get("interval"),
+		Location: time.UTC,
+	}
+
+	if rng.Interval == "" {
+		rng.Interval = "day"
+	}
+	if _, ok := timelineIntervals[rng.Interval]; !ok {
+		return rng, "interval must be one of hour, day, week or month"
+	}
+
+	if tz := query.Get("tz"); tz != "" {
+		location, err := time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			return rng, fmt.Sprintf("unknown tz %q, use an IANA time zone like Europe/Berlin", tz)
+		}
+		rng.Location = location
+	}
+
+	var msg string
+	if rng.From, msg = parseRangeBound(query.Get("from"), "from", rng.Location, false); msg != "" {
+		return rng, msg
+	}
+	if rng.To, msg = parseRangeBound(query.Get("to"), "to", rng.Location, true); msg != "" {
+		return rng, msg
+	}
+
+	if rng.From != nil && rng.To != nil && !rng.From.Before(*rng.To) {
+		return rng, "from must be before to"
+	}
+
+	if raw := query.Get("top"); raw != "" {
+		top, err := strconv.Atoi(raw)
+		if err != nil || top < 1 || top > maxTop {
+			return rng, fmt.Sprintf("top must be between 1 and %d", maxTop)
+		}
+		rng.Top = top
+	}
+
+	if rng.Bots, msg = parseBotFilter(query); msg != "" {
+		return rng, msg
+	}
+
+	return rng, ""
+}
+
+// parseBotFilter reads include_bots, bots are left out unless it is true.
+// "only" shows nothing but bots.
+func parseBotFilter(query url.Values) (database.BotFilter, string) {
+	raw := query.Get("include_bots")
+	if raw == "only" {
+		return database.OnlyBots, ""
+	}
+	if raw == "" {
+		return database.ExcludeBots, ""
+	}
+
+	include, err := strconv.ParseBool(raw)
+	if err != nil {
+		return database.ExcludeBots, "include_bots must be true, false or only"
+	}
+	if include {
+		return database.IncludeBots, ""
+	}
+
+	return database.ExcludeBots, ""
+}
+
+// parseRangeBound parses one end of a range, endOfDay moves dates to the
+// start of the following day.
+func parseRangeBound(raw string, name string, location *time.Location, endOfDay bool) (*time.Time, string) {
+	if raw == "" {
+		return nil, ""
+	}
+
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, ""
+	}
+
+	t, err := time.ParseInLocation(dateLayout, raw, location)
+	if err != nil {
+		return nil, fmt.Sprintf("%s must be an RFC 3339 time or a date like 2006-01-02", name)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return &t, ""
+}
+
+// timelineTooLong reports whether the timeline of rng would have more than
+// maxTimelineBuckets buckets when it begins at start. start is nil when
+// there is nothing to show.
+func timelineTooLong(rng database.ClickRange, start *time.Time, now time.Time) bool {
+	if start == nil {
+		return false
+	}
+
+	end := now
+	if rng.To != nil {
+		end = *rng.To
+	}
+
+	return end.Sub(*start)/timelineIntervals[rng.Interval] > maxTimelineBuckets
+}
+
+// timelineStart returns where the timeline of rng begins. Ranges without a
+// start begin at the first click of the link, nil when it has none.
+func (s *Server) timelineStart(linkId int, rng database.ClickRange) (*time.Time, error) {
+	if rng.From != nil {
+		return rng.From, nil
+	}
+
+	totals, err := s.db.GetClickTotals(linkId, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	return totals.FirstClick, nil
+}
+
+// checkTimeline answers with an error and returns false when the timeline
+// of rng can not be built.
+func (s *Server) checkTimeline(w http.ResponseWriter, linkId int, rng database.ClickRange) bool {
+	start, err := s.timelineStart(linkId, rng)
+	if err != nil {
+		log.Println("[CheckTimeline] error occured while getting the first click", err)
+		http.Error(w, "Some error occured, please check if the short link is valid, or try again later", http.StatusInternalServerError)
+		return false
+	}
+
+	if timelineTooLong(rng, start, time.Now()) {
+		http.Error(w, fmt.Sprintf("the range has more than %d buckets, use a larger interval", maxTimelineBuckets), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+type analyticsSummary struct {
+	database.ClickTotals
+	Timeline  []database.ClicksPerDay        `json:"timeline"`
+	Browsers  []database.ClicksPerBrowser    `json:"browsers"`
+	Referrers []database.TrafficFromReferrer `json:"referrers"`
+	Countries []database.TrafficFromCountry  `json:"countries"`
+}
+
+// getAnalyticsSummary answers with the totals, the timeline and the top
+// browsers, referrers and countries of a link in one response. It takes the
+// same parameters as the single endpoints, top defaults to 10 here. The
+// queries run concurrently.
+func (s *Server) getAnalyticsSummary(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if !s.checkTimeline(w, link.Id, rng) {
+		return
+	}
+	if rng.Top == 0 {
+		rng.Top = defaultSummaryTop
+	}
+
+	var summary analyticsSummary
+	var g errgroup.Group
+
+	g.Go(func() error {
+		totals, err := s.db.GetClickTotals(link.Id, rng)
+		if err == nil {
+			summary.ClickTotals = *totals
+		}
+		return err
+	})
+	g.Go(func() (err error) {
+		summary.Timeline, err = s.db.GetClicksOverTime(link.Id, rng)
+		return err
+	})
+	g.Go(func() (err error) {
+		summary.Browsers, err = s.db.GetBrowserStats(link.Id, rng)
+		return err
+	})
+	g.Go(func() (err error) {
+		summary.Referrers, err = s.db.GetReferrerStats(link.Id, rng, database.GroupByReferrer)
+		return err
+	})
+	g.Go(func() (err error) {
+		summary.Countries, err = s.db.GetCountryStats(link.Id, rng)
+		return err
+	})
+
+	if err := g.Wait(); err != nil {
+		log.Println("[GetAnalyticsSummary] Some error occured: ", err)
+		http.Error(w, "Some error occured, please check if the short link is valid, or try again later", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResp, err := json.Marshal(summary)
+	if err != nil {
+		log.Println("[GetAnalyticsSummary] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+func TestParseClickRange(t *testing.T) {
+	rng, msg := parseClickRange(url.Values{
+		"from":     {"2026-03-01"},
+		"to":       {"2026-03-31"},
+		"interval": {"week"},
+		"tz":       {"Europe/Berlin"},
+	})
+	if msg != "" {
+		t.Fatal(msg)
+	}
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	if want := time.Date(2026, 3, 1, 0, 0, 0, 0, berlin); !rng.From.Equal(want) {
+		t.Errorf("from = %s; want %s", rng.From, want)
+	}
+	// A date as the end of the range includes the whole day
+	if want := time.Date(2026, 4, 1, 0, 0, 0, 0, berlin); !rng.To.Equal(want) {
+		t.Errorf("to = %s; want %s", rng.To, want)
+	}
+	if rng.Interval != "week" || rng.Location.String() != "Europe/Berlin" {
+		t.Errorf("unexpected interval %q or location %s", rng.Interval, rng.Location)
+	}
+
+	rng, msg = parseClickRange(url.Values{"from": {"2026-03-01T10:00:00Z"}})
+	if msg != "" {
+		t.Fatal(msg)
+	}
+	if rng.To != nil || rng.Interval != "day" || rng.Location != time.UTC || rng.Bots != database.ExcludeBots {
+		t.Errorf("unexpected defaults %+v", rng)
+	}
+
+	bots := map[string]database.BotFilter{
+		"true":  database.IncludeBots,
+		"false": database.ExcludeBots,
+		"only":  database.OnlyBots,
+	}
+	for raw, want := range bots {
+		if rng, msg := parseClickRange(url.Values{"include_bots": {raw}}); msg != "" || rng.Bots != want {
+			t.Errorf("include_bots=%s: got %v (%q); want %v", raw, rng.Bots, msg, want)
+		}
+	}
+
+	invalid := []url.Values{
+		{"interval": {"minute"}},
+		{"tz": {"Mars/Olympus"}},
+		{"tz": {"Local"}},
+		{"from": {"yesterday"}},
+		{"from": {"2026-03-02"}, "to": {"2026-03-01"}},
+		{"top": {"0"}},
+		{"top": {"1000"}},
+		{"include_bots": {"sometimes"}},
+	}
+	for _, query := range invalid {
+		if _, msg := parseClickRange(query); msg == "" {
+			t.Errorf("expected %v to be rejected", query)
+		}
+	}
+}
+
+func TestTimelineTooLong(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	yearAgo := now.AddDate(-1, 0, 0)
+
+	if timelineTooLong(database.ClickRange{From: &yearAgo, Interval: "day"}, &yearAgo, now) {
+		t.Error("a year of days should be allowed")
+	}
+	if !timelineTooLong(database.ClickRange{From: &yearAgo, Interval: "hour"}, &yearAgo, now) {
+		t.Error("a year of hours should be rejected")
+	}
+	if !timelineTooLong(database.ClickRange{Interval: "hour"}, &yearAgo, now) {
+		t.Error("a year of hours since the first click should be rejected")
+	}
+	if timelineTooLong(database.ClickRange{Interval: "hour"}, nil, now) {
+		t.Error("links without clicks have no timeline to limit")
+	}
+}
+
+func TestParseReferrerGrouping(t *testing.T) {
+	cases := map[string]database.ReferrerGrouping{
+		"":         database.GroupByReferrer,
+		"referrer": database.GroupByReferrer,
+		"domain":   database.GroupByDomain,
+		"category": database.GroupByCategory,
+	}
+	for raw, want := range cases {
+		if got, msg := parseReferrerGrouping(raw); msg != "" || got != want {
+			t.Errorf("group_by=%s: got %q (%q); want %q", raw, got, msg, want)
+		}
+	}
+
+	if _, msg := parseReferrerGrouping("path"); msg == "" {
+		t.Error("expected group_by=path to be rejected")
+	}
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/scythe504/tiny-rl/internal"
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+type contextKey string
+
+const (
+	accountContextKey contextKey = "account"
+	linkContextKey    contextKey = "link"
+)
+
+// authMiddleware resolves the API key sent as "Authorization: Bearer <key>"
+// and stores the owning account in the request context. Requests without a
+// key pass through, handlers decide whether they need an account.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, found := strings.CutPrefix(header, "Bearer ")
+		if !found || key == "" {
+			writeJSONError(w, http.StatusUnauthorized, "invalid_api_key", "authorization header must be 'Bearer <api key>'")
+			return
+		}
+
+		account, err := s.db.GetAccountByKeyHash(internal.HashAPIKey(key))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeJSONError(w, http.StatusUnauthorized, "invalid_api_key", "the api key is invalid or has been revoked")
+				return
+			}
+			log.Println("[AuthMiddleware] error occured while looking up api key", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), accountContextKey, account)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// accountFromContext returns the authenticated account, or nil.
+func accountFromContext(ctx context.Context) *database.Account {
+	account, _ := ctx.Value(accountContextKey).(*database.Account)
+	return account
+}
+
+// requireAccount rejects requests that were not made with a valid API key.
+func (s *Server) requireAccount(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if accountFromContext(r.Context()) == nil {
+			writeJSONError(w, http.StatusUnauthorized, "api_key_required", "this endpoint requires an api key")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// requireLinkOwner only lets the account owning the {shortCode} of the route
+// through. Links on branded domains are addressed with ?domain=<hostname>.
+// The link is passed on in the request context. Deleted links have to be
+// restored first.
+func (s *Server) requireLinkOwner(next http.HandlerFunc) http.HandlerFunc {
+	return s.linkOwner(next, false)
+}
+
+// requireLinkOwnerOrDeleted is requireLinkOwner for the handlers deleting
+// and restoring links, which also accept deleted links.
+func (s *Server) requireLinkOwnerOrDeleted(next http.HandlerFunc) http.HandlerFunc {
+	return s.linkOwner(next, true)
+}
+
+func (s *Server) linkOwner(next http.HandlerFunc, allowDeleted bool) http.HandlerFunc {
+	return s.requireAccount(func(w http.ResponseWriter, r *http.Request) {
+		link, ok := s.ownedLink(w, r, r.URL.Query().Get("domain"), mux.Vars(r)["shortCode"], allowDeleted)
+		if !ok {
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), linkContextKey, link)
+		next(w, r.WithContext(ctx))
+	})
+}
+
+// linkFromContext returns the link loaded by requireLinkOwner.
+func linkFromContext(ctx context.Context) *database.LinkMap {
+	link, _ := ctx.Value(linkContextKey).(*database.LinkMap)
+	return link
+}
+
+// ownedLink loads a link and checks that it belongs to the authenticated
+// account and, unless allowDeleted is set, is not deleted. It writes the
+// error response and returns false otherwise.
+func (s *Server) ownedLink(w http.ResponseWriter, r *http.Request, domain string, shortCode string, allowDeleted bool) (*database.LinkMap, bool) {
+	account := accountFromContext(r.Context())
+	if account == nil {
+		writeJSONError(w, http.StatusUnauthorized, "api_key_required", "this endpoint requires an api key")
+		return nil, false
+	}
+
+	link, err := s.db.GetLink(domain, shortCode)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "short url is invalid", http.StatusNotFound)
+			return nil, false
+		}
+		log.Println("[OwnedLink] error occured while getting link", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if link.OwnerId == nil || *link.OwnerId != account.Id {
+		writeJSONError(w, http.StatusForbidden, "not_link_owner", "this link belongs to another account")
+		return nil, false
+	}
+
+	if link.DeletedAt != nil && !allowDeleted {
+		writeJSONError(w, http.StatusConflict, "link_deleted", "this link is deleted, restore it first")
+		return nil, false
+	}
+
+	return link, true
+}
+
+func (s *Server) listApiKeys(w http.ResponseWriter, r *http.Request) {
+	account := accountFromContext(r.Context())
+
+	keys, err := s.db.ListApiKeys(account.Id)
+	if err != nil {
+		log.Println("[ListApiKeys] Some error occured: ", err)
+		http.Error(w, "failed to list api keys", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResp, err := json.Marshal(keys)
+	if err != nil {
+		log.Println("[ListApiKeys] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+func (s *Server) createApiKey(w http.ResponseWriter, r *http.Request) {
+	account := accountFromContext(r.Context())
+
+	key, prefix, err := internal.GenerateAPIKey()
+	if err != nil {
+		log.Println("[CreateApiKey] error while generating key", err)
+		http.Error(w, "failed to create api key", http.StatusInternalServerError)
+		return
+	}
+
+	apiKey, err := s.db.CreateApiKey(account.Id, prefix, internal.HashAPIKey(key))
+	if err != nil {
+		log.Println("[CreateApiKey] database error: ", err)
+		http.Error(w, "failed to create api key", http.StatusInternalServerError)
+		return
+	}
+
+	// The clear text key is only ever shown in this response
+	var resp = struct {
+		Key  string           `json:"key"`
+		Data *database.ApiKey `json:"data"`
+	}{
+		Key:  key,
+		Data: apiKey,
+	}
+
+	jsonResp, err := json.Marshal(resp)
+	if err != nil {
+		log.Println("[CreateApiKey] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonResp)
+}
+
+func (s *Server) revokeApiKey(w http.ResponseWriter, r *http.Request) {
+	account := accountFromContext(r.Context())
+
+	keyId, err := strconv.Atoi(mux.Vars(r)["keyId"])
+	if err != nil {
+		http.Error(w, "invalid key id", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := s.db.RevokeApiKey(account.Id, keyId)
+	if err != nil {
+		log.Println("[RevokeApiKey] database error: ", err)
+		http.Error(w, "failed to revoke api key", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, "api key not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"message\": \"success\"}"))
+}
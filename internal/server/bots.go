@@ -0,0 +1,53 @@
+package server
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/scythe504/tiny-rl/internal/bots"
+	"github.com/scythe504/tiny-rl/internal/geodatabase"
+)
+
+// newBotClassifier flags the user agents of bots.DefaultUserAgents and the
+// comma separated BOT_USER_AGENTS. IPs of the BOT_ASNS networks, e.g. cloud
+// providers running uptime checks, count as bots too when ASN_DB_PATH points
+// at a GeoLite2-ASN database.
+func newBotClassifier() *bots.Classifier {
+	userAgents := append([]string{}, bots.DefaultUserAgents...)
+	for _, fragment := range strings.Split(os.Getenv("BOT_USER_AGENTS"), ",") {
+		if fragment = strings.TrimSpace(fragment); fragment != "" {
+			userAgents = append(userAgents, fragment)
+		}
+	}
+
+	var asns []uint
+	for _, raw := range strings.Split(os.Getenv("BOT_ASNS"), ",") {
+		raw = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(raw)), "AS")
+		if raw == "" {
+			continue
+		}
+
+		asn, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			log.Printf("[Config] ignoring invalid ASN %q in BOT_ASNS\n", raw)
+			continue
+		}
+		asns = append(asns, uint(asn))
+	}
+
+	var lookup bots.ASNLookup
+	if path := os.Getenv("ASN_DB_PATH"); path != "" && len(asns) > 0 {
+		asnDB, err := geodatabase.NewASN(path)
+		if err != nil {
+			log.Println("[Config] ASN database could not be opened, BOT_ASNS are ignored", err)
+		} else {
+			lookup = asnDB.GetASNByIP
+		}
+	} else if len(asns) > 0 {
+		log.Println("[Config] BOT_ASNS is set without ASN_DB_PATH and is ignored")
+	}
+
+	return bots.NewClassifier(userAgents, asns, lookup)
+}
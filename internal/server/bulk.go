@@ -0,0 +1,217 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/scythe504/tiny-rl/internal"
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+// maxBulkRows keeps a single batch within one multi-row INSERT
+const maxBulkRows = 500
+
+const maxBulkBodyBytes = 5 << 20
+
+type bulkRow struct {
+	URL   string `json:"url"`
+	Alias string `json:"alias"`
+}
+
+type bulkResult struct {
+	Row       int    `json:"row"`
+	URL       string `json:"url"`
+	ShortCode string `json:"short_code,omitempty"`
+	ShortURL  string `json:"short_url,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// ErrorCode is the reason code of urls rejected by the url policy
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// shortenBulk shortens a batch of URLs sent either as a JSON array, a CSV body
+// or a CSV file uploaded as the "file" field of a multipart form. Every row
+// gets its own result so one bad URL does not fail the whole batch. All links
+// are created on the branded domain given as ?domain=, if any.
+func (s *Server) shortenBulk(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBulkBodyBytes)
+
+	rows, err := readBulkRows(r)
+	if err != nil {
+		log.Println("[ShortenBulk] error while reading body: ", err)
+		http.Error(w, fmt.Sprintf("error in parsing request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if len(rows) == 0 {
+		http.Error(w, "no urls to shorten", http.StatusBadRequest)
+		return
+	}
+
+	if len(rows) > maxBulkRows {
+		http.Error(w, fmt.Sprintf("too many urls, at most %d per request", maxBulkRows), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	account := accountFromContext(r.Context())
+
+	domain, ok := s.linkDomain(w, account, r.URL.Query().Get("domain"))
+	if !ok {
+		return
+	}
+
+	results := make([]bulkResult, len(rows))
+	links := make([]database.BulkLink, 0, len(rows))
+	// index into results for every entry of links
+	linkRows := make([]int, 0, len(rows))
+	aliases := make(map[string]bool)
+
+	urls := make([]string, len(rows))
+	for i := range rows {
+		rows[i].URL = strings.TrimSpace(rows[i].URL)
+		rows[i].Alias = strings.TrimSpace(rows[i].Alias)
+		urls[i] = rows[i].URL
+	}
+	targets, urlErrs := s.linkDestinations(r.Context(), urls)
+
+	for i, row := range rows {
+		results[i] = bulkResult{Row: i + 1, URL: row.URL}
+
+		switch {
+		case urlErrs[i] != nil:
+			results[i].ErrorCode, results[i].Error = rejectionReason(urlErrs[i])
+			continue
+		case row.Alias != "" && !internal.ValidAlias(row.Alias):
+			results[i].Error = "invalid alias"
+			continue
+		case row.Alias != "" && aliases[row.Alias]:
+			results[i].Error = "alias is used more than once in this batch"
+			continue
+		}
+
+		link := database.BulkLink{
+			Link: database.LinkMap{
+				ShortCode:   row.Alias,
+				Url:         targets[i].Url,
+				OriginalUrl: targets[i].OriginalUrl,
+				OwnerId:     &account.Id,
+				DomainId:    domain.Id,
+			},
+			Generated: row.Alias == "",
+		}
+		if !link.Generated {
+			aliases[row.Alias] = true
+		}
+
+		links = append(links, link)
+		linkRows = append(linkRows, i)
+	}
+
+	if len(links) > 0 {
+		if err := s.db.InsertShortenedLinks(links, s.codes); err != nil {
+			log.Println("[ShortenBulk] database error: ", err)
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for i, link := range links {
+		result := &results[linkRows[i]]
+
+		switch {
+		case link.Inserted:
+			result.ShortCode = link.Link.ShortCode
+			result.ShortURL = shortURL(domain.Hostname, link.Link.ShortCode)
+		case link.Generated:
+			result.Error = "error while generating short code"
+		default:
+			result.Error = "alias is already taken"
+		}
+	}
+
+	var resp = struct {
+		Data []bulkResult `json:"data"`
+	}{
+		Data: results,
+	}
+
+	jsonResp, err := json.Marshal(resp)
+	if err != nil {
+		log.Println("[ShortenBulk] error while marshaling resp into json ", err)
+		http.Error(w, "error while sending shortened urls", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+// readBulkRows decodes the batch according to the request Content-Type.
+func readBulkRows(r *http.Request) ([]bulkRow, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "application/json"
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return readCSVRows(r.Body)
+	case "multipart/form-data":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New("missing csv file in field \"file\"")
+		}
+		defer file.Close()
+
+		return readCSVRows(file)
+	default:
+		var rows []bulkRow
+		if err := json.NewDecoder(r.Body).Decode(&rows); err != nil {
+			return nil, err
+		}
+
+		return rows, nil
+	}
+}
+
+// readCSVRows reads "url[,alias]" records, a leading header row is skipped.
+func readCSVRows(reader io.Reader) ([]bulkRow, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	var rows []bulkRow
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rows) == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "url") {
+			continue
+		}
+
+		row := bulkRow{URL: record[0]}
+		if len(record) > 1 {
+			row.Alias = record[1]
+		}
+
+		rows = append(rows, row)
+
+		// Stop early instead of reading a huge upload into memory
+		if len(rows) > maxBulkRows {
+			break
+		}
+	}
+
+	return rows, nil
+}
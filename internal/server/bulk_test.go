@@ -0,0 +1,31 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadCSVRows(t *testing.T) {
+	input := "url,alias\nhttps://example.com/a,spring-sale\nhttps://example.com/b\n\n\"https://example.com/c?x=1,2\", \n"
+
+	rows, err := readCSVRows(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []bulkRow{
+		{URL: "https://example.com/a", Alias: "spring-sale"},
+		{URL: "https://example.com/b"},
+		{URL: "https://example.com/c?x=1,2"},
+	}
+
+	if len(rows) != len(expected) {
+		t.Fatalf("expected %d rows; got %d: %v", len(expected), len(rows), rows)
+	}
+
+	for i := range expected {
+		if rows[i] != expected[i] {
+			t.Errorf("row %d: expected %v; got %v", i, expected[i], rows[i])
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+	"github.com/scythe504/tiny-rl/internal/shortcode"
+)
+
+const (
+	defaultCodeLength        = 6
+	defaultMaxCodeLength     = 12
+	defaultPrivateCodeLength = 22
+	defaultMaxCollisionRate  = 0.01
+
+	// minPrivateCodeBits keeps private codes unguessable
+	minPrivateCodeBits = 128
+)
+
+// newCodeGenerators builds the generators for public and private links from
+// the SHORTCODE_* env vars. Private links always get long random base62
+// codes, whatever alphabet public codes use. The default 22 characters are
+// about 130 bits, shorter codes are rejected.
+func newCodeGenerators(db database.Service) (codes shortcode.Generator, privateCodes shortcode.Generator) {
+	strategy := os.Getenv("SHORTCODE_STRATEGY")
+	length := envInt("SHORTCODE_LENGTH", defaultCodeLength)
+
+	alphabet := os.Getenv("SHORTCODE_ALPHABET")
+	if alphabet == "" {
+		alphabet = shortcode.DefaultAlphabet
+		if strategy == "counter" || strategy == "feistel" {
+			alphabet = shortcode.Base62Alphabet
+		}
+	}
+	if excludeLookalikes, _ := strconv.ParseBool(os.Getenv("SHORTCODE_EXCLUDE_LOOKALIKES")); excludeLookalikes {
+		alphabet = shortcode.ExcludeLookalikes(alphabet)
+	}
+
+	var err error
+	switch strategy {
+	case "", "random":
+		codes, err = shortcode.NewRandom(alphabet, length,
+			envInt("SHORTCODE_MAX_LENGTH", defaultMaxCodeLength),
+			envFloat("SHORTCODE_MAX_COLLISION_RATE", defaultMaxCollisionRate))
+	case "counter":
+		codes, err = shortcode.NewCounter(db.NextShortCodeId, alphabet, length, nil)
+	case "feistel":
+		key := os.Getenv("SHORTCODE_FEISTEL_KEY")
+		if key == "" {
+			log.Fatal("[ShortCodes] SHORTCODE_FEISTEL_KEY is required for the feistel strategy")
+		}
+		codes, err = shortcode.NewCounter(db.NextShortCodeId, alphabet, length, []byte(key))
+	default:
+		log.Fatalf("[ShortCodes] unknown SHORTCODE_STRATEGY %q, use random, counter or feistel", strategy)
+	}
+	if err != nil {
+		log.Fatal("[ShortCodes] invalid short code settings: ", err)
+	}
+
+	privateLength := envInt("SHORTCODE_PRIVATE_LENGTH", defaultPrivateCodeLength)
+	if bits := shortcode.Entropy(shortcode.Base62Alphabet, privateLength); bits < minPrivateCodeBits {
+		log.Fatalf("[ShortCodes] SHORTCODE_PRIVATE_LENGTH %d gives private codes %.0f bits, at least %d are needed", privateLength, bits, minPrivateCodeBits)
+	}
+	privateCodes, err = shortcode.NewRandom(shortcode.Base62Alphabet, privateLength, privateLength, 0)
+	if err != nil {
+		log.Fatal("[ShortCodes] invalid short code settings: ", err)
+	}
+
+	return codes, privateCodes
+}
+
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Printf("[Config] invalid %s %q, using %d\n", name, value, fallback)
+		return fallback
+	}
+
+	return n
+}
+
+func envFloat(name string, fallback float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		log.Printf("[Config] invalid %s %q, using %v\n", name, value, fallback)
+		return fallback
+	}
+
+	return f
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// deepLinkPage tries to open an app deep link and moves on to the web
+// fallback when nothing happened, e.g. because the app is not installed.
+// A plain redirect cannot do this, browsers just show an error page for
+// schemes nobody handles.
+var deepLinkPage = template.Must(template.New("deeplink").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Opening…</title>
+</head>
+<body>
+<p>Opening the app… If nothing happens, <a href="{{.Fallback}}">continue here</a>.</p>
+<script>
+(function () {
+  var fallback = {{.Fallback}};
+  var timer = setTimeout(function () { window.location.replace(fallback); }, 1500);
+  document.addEventListener("visibilitychange", function () {
+    if (document.hidden) { clearTimeout(timer); }
+  });
+  window.location.href = {{.DeepLink}};
+})();
+</script>
+</body>
+</html>
+`))
+
+// isWebURL reports whether rawUrl can be handed to the browser with a normal
+// redirect.
+func isWebURL(rawUrl string) bool {
+	uri, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+
+	return uri.Scheme == "http" || uri.Scheme == "https"
+}
+
+// writeDeepLinkPage serves the interstitial opening dest.url with
+// dest.fallbackUrl as the way out.
+func writeDeepLinkPage(w http.ResponseWriter, dest destination) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	err := deepLinkPage.Execute(w, struct {
+		DeepLink template.URL
+		Fallback string
+	}{
+		// The scheme was checked by ValidDeepLink, template.URL keeps
+		// html/template from rewriting it to #ZgotmplZ
+		DeepLink: template.URL(dest.url),
+		Fallback: dest.fallbackUrl,
+	})
+	if err != nil {
+		log.Println("[DeepLinkPage] error while rendering page", err)
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"hash/fnv"
+	"log"
+	"math/rand/v2"
+
+	"github.com/mileusna/useragent"
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+// destination is where a visit is sent and which rule picked it.
+type destination struct {
+	url string
+	// fallbackUrl is opened when url is a deep link the device cannot handle
+	fallbackUrl  string
+	deviceRuleId *int
+	geoRuleId    *int
+	variantId    *int
+}
+
+// resolveDestination applies the routing rules of a link to a visit, device
+// rules first, then geo rules and finally the A/B split. The link's own url
+// is the fallback whenever no rule matches, lookup errors fall back to it as
+// well so a broken rule never breaks the link. All rules are loaded with a
+// single query as this runs on every redirect.
+func (s *Server) resolveDestination(link *database.LinkMap, v *visit) destination {
+	dest := destination{url: link.Url}
+
+	rules, err := s.db.GetLinkRules(link.Id)
+	if err != nil {
+		log.Println("[ResolveDestination] error occured while getting rules", err)
+		return dest
+	}
+
+	if rule := matchDeviceRule(rules.DeviceRules, v.ua); rule != nil {
+		dest.url = rule.Url
+		dest.fallbackUrl = rule.FallbackUrl
+		dest.deviceRuleId = &rule.Id
+		return dest
+	}
+
+	if rule := matchGeoRule(rules.GeoRules, v.countryISOCode()); rule != nil {
+		dest.url = rule.Url
+		dest.geoRuleId = &rule.Id
+		return dest
+	}
+
+	if variant := pickVariant(rules.Variants, link.ShortCode, v.stickyKey()); variant != nil {
+		dest.url = variant.Url
+		dest.variantId = &variant.Id
+	}
+
+	return dest
+}
+
+// pickVariant chooses a variant proportionally to its weight. The choice is
+// derived from stickyKey, so a visitor keeps seeing the same variant for as
+// long as the key stays the same. Without a key the pick is random.
+func pickVariant(variants []database.Variant, shortCode string, stickyKey string) *database.Variant {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	var point int
+	if stickyKey == "" {
+		point = rand.IntN(total)
+	} else {
+		h := fnv.New64a()
+		h.Write([]byte(shortCode))
+		h.Write([]byte(stickyKey))
+		point = int(h.Sum64() % uint64(total))
+	}
+
+	for i := range variants {
+		if point < variants[i].Weight {
+			return &variants[i]
+		}
+		point -= variants[i].Weight
+	}
+
+	return nil
+}
+
+// matchGeoRule returns the rule for the visitor's country, if any.
+func matchGeoRule(rules []database.GeoRule, countryISOCode string) *database.GeoRule {
+	if countryISOCode == "" {
+		return nil
+	}
+
+	for i := range rules {
+		if rules[i].CountryISOCode == countryISOCode {
+			return &rules[i]
+		}
+	}
+
+	return nil
+}
+
+const anyValue = "any"
+
+// deviceRuleOS maps the os of a device rule to useragent's OS names
+var deviceRuleOS = map[string]string{
+	"ios":      useragent.IOS,
+	"android":  useragent.Android,
+	"windows":  useragent.Windows,
+	"macos":    useragent.MacOS,
+	"linux":    useragent.Linux,
+	"chromeos": useragent.ChromeOS,
+}
+
+var deviceRuleDevices = map[string]bool{
+	"mobile":  true,
+	"tablet":  true,
+	"desktop": true,
+}
+
+// validDeviceRule reports whether os and device are known rule values.
+func validDeviceRule(os string, device string) bool {
+	_, knownOS := deviceRuleOS[os]
+
+	return (os == anyValue || knownOS) && (device == anyValue || deviceRuleDevices[device])
+}
+
+// deviceType classifies a user agent as mobile, tablet or desktop.
+func deviceType(ua useragent.UserAgent) string {
+	switch {
+	case ua.Tablet:
+		return "tablet"
+	case ua.Mobile:
+		return "mobile"
+	case ua.Desktop:
+		return "desktop"
+	}
+
+	return ""
+}
+
+// matchDeviceRule returns the first rule matching the visitor's OS and device.
+func matchDeviceRule(rules []database.DeviceRule, ua useragent.UserAgent) *database.DeviceRule {
+	device := deviceType(ua)
+
+	for i := range rules {
+		rule := &rules[i]
+
+		if rule.OS != anyValue && deviceRuleOS[rule.OS] != ua.OS {
+			continue
+		}
+		if rule.Device != anyValue && rule.Device != device {
+			continue
+		}
+
+		return rule
+	}
+
+	return nil
+}
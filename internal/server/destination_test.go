@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mileusna/useragent"
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+func TestMatchDeviceRule(t *testing.T) {
+	rules := []database.DeviceRule{
+		{Id: 1, OS: "ios", Device: "mobile", Url: "myapp://home"},
+		{Id: 2, OS: "android", Device: "mobile", Url: "intent://home"},
+		{Id: 3, OS: "any", Device: "tablet", Url: "https://example.com/tablet"},
+	}
+
+	cases := []struct {
+		name      string
+		userAgent string
+		want      int
+	}{
+		{"iphone", "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.0 Mobile/15E148 Safari/604.1", 1},
+		{"android phone", "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Mobile Safari/537.36", 2},
+		{"ipad", "Mozilla/5.0 (iPad; CPU OS 15_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.6 Mobile/15E148 Safari/604.1", 3},
+		{"desktop", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36", 0},
+	}
+
+	for _, c := range cases {
+		rule := matchDeviceRule(rules, useragent.Parse(c.userAgent))
+
+		got := 0
+		if rule != nil {
+			got = rule.Id
+		}
+		if got != c.want {
+			t.Errorf("%s: expected rule %d; got %d", c.name, c.want, got)
+		}
+	}
+}
+
+func TestMatchGeoRule(t *testing.T) {
+	rules := []database.GeoRule{
+		{Id: 1, CountryISOCode: "DE"},
+		{Id: 2, CountryISOCode: "US"},
+	}
+
+	if rule := matchGeoRule(rules, "US"); rule == nil || rule.Id != 2 {
+		t.Errorf("expected US rule; got %v", rule)
+	}
+
+	if rule := matchGeoRule(rules, "FR"); rule != nil {
+		t.Errorf("expected no rule for FR; got %v", rule)
+	}
+
+	if rule := matchGeoRule(rules, ""); rule != nil {
+		t.Errorf("expected no rule for unknown country; got %v", rule)
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := []database.Variant{
+		{Id: 1, Weight: 70},
+		{Id: 2, Weight: 30},
+	}
+
+	first := pickVariant(variants, "promo", "visitor-hash")
+	for range 10 {
+		if got := pickVariant(variants, "promo", "visitor-hash"); got.Id != first.Id {
+			t.Fatalf("expected sticky variant %d; got %d", first.Id, got.Id)
+		}
+	}
+
+	counts := map[int]int{}
+	for i := range 10000 {
+		counts[pickVariant(variants, "promo", fmt.Sprintf("visitor-%d", i)).Id]++
+	}
+	if counts[1] < 6500 || counts[1] > 7500 {
+		t.Errorf("expected roughly 70%% for variant 1; got %d of 10000", counts[1])
+	}
+
+	if got := pickVariant(nil, "promo", "visitor-hash"); got != nil {
+		t.Errorf("expected no variant without variants; got %v", got)
+	}
+}
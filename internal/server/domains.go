@@ -0,0 +1,111 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+// shortURL is the public url of a short code on a domain. The default domain
+// is served under FRONTEND_URL, branded domains over https.
+func shortURL(domain string, shortCode string) string {
+	if domain == "" {
+		return fmt.Sprintf("%s/%s", FRONTEND_URL, shortCode)
+	}
+
+	return fmt.Sprintf("https://%s/%s", domain, shortCode)
+}
+
+// linkHost is the hostname a visitor used to open a short link. Frontends
+// resolving links through the API pass it along as ?domain=.
+func linkHost(r *http.Request) string {
+	host := r.URL.Query().Get("domain")
+	if host == "" {
+		host = r.Host
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(host)
+}
+
+// linkDomain looks up the domain a new link is created on and checks that
+// the account may use it. It writes the error response and returns false
+// otherwise.
+func (s *Server) linkDomain(w http.ResponseWriter, account *database.Account, hostname string) (*database.Domain, bool) {
+	domain, err := s.db.GetDomain(strings.ToLower(strings.TrimSpace(hostname)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusBadRequest, "unknown_domain", "the domain is not configured")
+			return nil, false
+		}
+		log.Println("[LinkDomain] error occured while getting domain", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if domain.AccountId != nil && *domain.AccountId != account.Id {
+		writeJSONError(w, http.StatusForbidden, "domain_not_allowed", "this domain belongs to another account")
+		return nil, false
+	}
+
+	return domain, true
+}
+
+func (s *Server) listDomains(w http.ResponseWriter, r *http.Request) {
+	account := accountFromContext(r.Context())
+
+	domains, err := s.db.ListDomains(account.Id)
+	if err != nil {
+		log.Println("[ListDomains] Some error occured: ", err)
+		http.Error(w, "failed to list domains", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResp, err := json.Marshal(domains)
+	if err != nil {
+		log.Println("[ListDomains] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+func (s *Server) getDomainAnalytics(w http.ResponseWriter, r *http.Request) {
+	account := accountFromContext(r.Context())
+
+	bots, msg := parseBotFilter(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	clicksPerDomain, err := s.db.GetDomainStats(account.Id, bots)
+	if err != nil {
+		log.Println("[GetDomainAnalytics] Some error occured: ", err)
+		http.Error(w, "Some error occured, please try again later", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResp, err := json.Marshal(clicksPerDomain)
+	if err != nil {
+		log.Println("[GetDomainAnalytics] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+	"github.com/scythe504/tiny-rl/internal/export"
+)
+
+// newExportPrivacy loads the privacy settings of click exports, see
+// export.PrivacyFromEnv. Invalid settings are fatal, an export must never
+// contain more than configured.
+func newExportPrivacy() *export.Privacy {
+	privacy, err := export.PrivacyFromEnv()
+	if err != nil {
+		log.Fatal("[Config] invalid click export privacy settings: ", err)
+	}
+
+	return privacy
+}
+
+// exportLinkClicks streams the raw clicks of one link.
+func (s *Server) exportLinkClicks(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+	s.exportClicks(w, r, link.Id, "clicks-"+link.ShortCode)
+}
+
+// exportAccountClicks streams the raw clicks of every link of the account.
+func (s *Server) exportAccountClicks(w http.ResponseWriter, r *http.Request) {
+	account := accountFromContext(r.Context())
+	s.exportClicks(w, r, 0, fmt.Sprintf("clicks-account-%d", account.Id))
+}
+
+// exportClicks writes the clicks as format=csv|ndjson|parquet within the
+// range and bot filter of the analytics endpoints. drop leaves out more
+// fields than the privacy settings do. Rows are streamed while they are read
+// from the database, an error after the first row aborts the response.
+func (s *Server) exportClicks(w http.ResponseWriter, r *http.Request, linkId int, name string) {
+	account := accountFromContext(r.Context())
+	query := r.URL.Query()
+
+	rng, msg := parseClickRange(query)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	format, err := export.ParseFormat(query.Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	privacy := s.exportPrivacy
+	if raw := query.Get("drop"); raw != "" {
+		if privacy, err = privacy.Drop(strings.Split(raw, ",")); err != nil {
+			http.Error(w, "drop: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var writer *export.Writer
+	start := func() error {
+		// Exports easily outlast the server's write timeout
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			log.Println("[ExportClicks] could not clear the write deadline", err)
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+		w.WriteHeader(http.StatusOK)
+
+		var err error
+		writer, err = export.NewWriter(w, format, privacy)
+		return err
+	}
+
+	// Nothing is sent before the first row, so failing queries still get a
+	// proper error response
+	started := false
+	err = s.db.ExportClicks(r.Context(), account.Id, linkId, rng, func(click *database.ExportedClick) error {
+		if !started {
+			started = true
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return writer.Write(click)
+	})
+	if err == nil && !started {
+		started = true
+		err = start()
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if err != nil {
+		if !started {
+			log.Println("[ExportClicks] Some error occured: ", err)
+			http.Error(w, "Some error occured, please try again later", http.StatusInternalServerError)
+			return
+		}
+
+		// The status is sent already, cutting the connection tells the client
+		// the file is incomplete
+		log.Println("[ExportClicks] export aborted", err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,323 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/scythe504/tiny-rl/internal"
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+// isoCountryCode matches ISO 3166-1 alpha-2 codes as reported by GeoLite2
+var isoCountryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+func (s *Server) deleteLink(w http.ResponseWriter, r *http.Request) {
+	s.updateLinkState(w, r, "[DeleteLink]", func(linkId int) (bool, error) {
+		return s.db.SetLinkDeleted(linkId, true)
+	})
+}
+
+func (s *Server) restoreLink(w http.ResponseWriter, r *http.Request) {
+	s.updateLinkState(w, r, "[RestoreLink]", func(linkId int) (bool, error) {
+		return s.db.SetLinkDeleted(linkId, false)
+	})
+}
+
+func (s *Server) disableLink(w http.ResponseWriter, r *http.Request) {
+	s.updateLinkState(w, r, "[DisableLink]", func(linkId int) (bool, error) {
+		return s.db.SetLinkDisabled(linkId, true)
+	})
+}
+
+func (s *Server) enableLink(w http.ResponseWriter, r *http.Request) {
+	s.updateLinkState(w, r, "[EnableLink]", func(linkId int) (bool, error) {
+		return s.db.SetLinkDisabled(linkId, false)
+	})
+}
+
+// updateLinkState applies a deleted/disabled state change to the {shortCode}
+// of the route. Ownership is checked by requireLinkOwner beforehand.
+func (s *Server) updateLinkState(w http.ResponseWriter, r *http.Request, logTag string, update func(linkId int) (bool, error)) {
+	link := linkFromContext(r.Context())
+
+	found, err := update(link.Id)
+	if err != nil {
+		log.Println(logTag, "Failed to update link state", err)
+		http.Error(w, "failed to update link", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "short url is invalid", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"message\": \"success\"}"))
+}
+
+func (s *Server) getLinkRevisions(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	revisions, err := s.db.GetLinkRevisions(link.Id)
+	if err != nil {
+		log.Println("[GetLinkRevisions] Some error occured: ", err)
+		http.Error(w, "failed to get revisions", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResp, err := json.Marshal(revisions)
+	if err != nil {
+		log.Println("[GetLinkRevisions] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+func (s *Server) rollbackLink(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	revision, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	newRevision, err := s.db.RollbackLink(linkFromContext(r.Context()).Id, revision)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+		log.Println("[RollbackLink] Failed to roll back link", err)
+		http.Error(w, "failed to roll back link", http.StatusInternalServerError)
+		return
+	}
+
+	var resp = map[string]any{
+		"data": newRevision,
+	}
+
+	jsonResp, err := json.Marshal(resp)
+	if err != nil {
+		log.Println("[RollbackLink] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+func (s *Server) getGeoRules(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	rules, err := s.db.GetGeoRules(link.Id)
+	if err != nil {
+		log.Println("[GetGeoRules] Some error occured: ", err)
+		http.Error(w, "failed to get geo rules", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResp, err := json.Marshal(rules)
+	if err != nil {
+		log.Println("[GetGeoRules] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+// replaceGeoRules sets the country rules of a link. Visitors from any other
+// country keep going to the link's own url.
+func (s *Server) replaceGeoRules(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	var body struct {
+		Rules []database.GeoRule `json:"rules"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println("[ReplaceGeoRules] Invalid request body", err)
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	countries := make(map[string]bool, len(body.Rules))
+	for i := range body.Rules {
+		rule := &body.Rules[i]
+		rule.CountryISOCode = strings.ToUpper(strings.TrimSpace(rule.CountryISOCode))
+
+		if !isoCountryCode.MatchString(rule.CountryISOCode) {
+			http.Error(w, fmt.Sprintf("invalid country_iso_code %q", rule.CountryISOCode), http.StatusBadRequest)
+			return
+		}
+		if countries[rule.CountryISOCode] {
+			http.Error(w, fmt.Sprintf("country %s has more than one rule", rule.CountryISOCode), http.StatusBadRequest)
+			return
+		}
+		if !s.checkDestination(w, r, fmt.Sprintf("url for country %s", rule.CountryISOCode), rule.Url) {
+			return
+		}
+
+		countries[rule.CountryISOCode] = true
+	}
+
+	if err := s.db.ReplaceGeoRules(link.Id, body.Rules); err != nil {
+		log.Println("[ReplaceGeoRules] Failed to replace geo rules", err)
+		http.Error(w, "failed to update geo rules", http.StatusInternalServerError)
+		return
+	}
+
+	s.getGeoRules(w, r)
+}
+
+func (s *Server) getDeviceRules(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	rules, err := s.db.GetDeviceRules(link.Id)
+	if err != nil {
+		log.Println("[GetDeviceRules] Some error occured: ", err)
+		http.Error(w, "failed to get device rules", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResp, err := json.Marshal(rules)
+	if err != nil {
+		log.Println("[GetDeviceRules] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+// replaceDeviceRules sets the OS and device rules of a link, the first rule
+// matching a visitor wins. Rule urls may be app deep links, the fallback url
+// has to be a regular web url.
+func (s *Server) replaceDeviceRules(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	var body struct {
+		Rules []database.DeviceRule `json:"rules"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println("[ReplaceDeviceRules] Invalid request body", err)
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	for i := range body.Rules {
+		rule := &body.Rules[i]
+		rule.OS = strings.ToLower(strings.TrimSpace(rule.OS))
+		rule.Device = strings.ToLower(strings.TrimSpace(rule.Device))
+		if rule.OS == "" {
+			rule.OS = anyValue
+		}
+		if rule.Device == "" {
+			rule.Device = anyValue
+		}
+
+		if !validDeviceRule(rule.OS, rule.Device) {
+			http.Error(w, fmt.Sprintf("rule %d: unknown os %q or device %q", i+1, rule.OS, rule.Device), http.StatusBadRequest)
+			return
+		}
+		if isWebURL(rule.Url) {
+			if !s.checkDestination(w, r, fmt.Sprintf("rule %d url", i+1), rule.Url) {
+				return
+			}
+		} else if !internal.ValidDeepLink(rule.Url) {
+			http.Error(w, fmt.Sprintf("rule %d: invalid url", i+1), http.StatusBadRequest)
+			return
+		}
+		if !s.checkDestination(w, r, fmt.Sprintf("rule %d fallback_url", i+1), rule.FallbackUrl) {
+			return
+		}
+	}
+
+	if err := s.db.ReplaceDeviceRules(link.Id, body.Rules); err != nil {
+		log.Println("[ReplaceDeviceRules] Failed to replace device rules", err)
+		http.Error(w, "failed to update device rules", http.StatusInternalServerError)
+		return
+	}
+
+	s.getDeviceRules(w, r)
+}
+
+func (s *Server) getVariants(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	variants, err := s.db.GetVariants(link.Id)
+	if err != nil {
+		log.Println("[GetVariants] Some error occured: ", err)
+		http.Error(w, "failed to get variants", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResp, err := json.Marshal(variants)
+	if err != nil {
+		log.Println("[GetVariants] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+// replaceVariants sets the weighted A/B split of a link, e.g.
+// {"variants":[{"url":"...","weight":70},{"url":"...","weight":30}]}.
+// An empty list turns the split off again.
+func (s *Server) replaceVariants(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	var body struct {
+		Variants []database.Variant `json:"variants"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println("[ReplaceVariants] Invalid request body", err)
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if len(body.Variants) == 1 {
+		http.Error(w, "a split needs at least two variants", http.StatusBadRequest)
+		return
+	}
+
+	for i, variant := range body.Variants {
+		if variant.Weight < 1 {
+			http.Error(w, fmt.Sprintf("variant %d: weight must be at least 1", i+1), http.StatusBadRequest)
+			return
+		}
+		if !s.checkDestination(w, r, fmt.Sprintf("variant %d url", i+1), variant.Url) {
+			return
+		}
+	}
+
+	if err := s.db.ReplaceVariants(link.Id, body.Variants); err != nil {
+		log.Println("[ReplaceVariants] Failed to replace variants", err)
+		http.Error(w, "failed to update variants", http.StatusInternalServerError)
+		return
+	}
+
+	s.getVariants(w, r)
+}
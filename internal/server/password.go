@@ -0,0 +1,40 @@
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// passwordPage asks browsers following a protected short link for the
+// password. They can not send X-Link-Password, so the form posts it back to
+// the short link itself.
+var passwordPage = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Password required</title>
+</head>
+<body>
+<form method="post">
+<p>{{.Message}}</p>
+<label for="password">Password</label>
+<input id="password" name="password" type="password" autocomplete="current-password" required autofocus>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// writePasswordPage serves the password form with message explaining why it
+// is shown.
+func writePasswordPage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := passwordPage.Execute(w, struct{ Message string }{message}); err != nil {
+		log.Println("[PasswordPage] error while rendering page", err)
+	}
+}
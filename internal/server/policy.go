@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+	"github.com/scythe504/tiny-rl/internal/urlpolicy"
+)
+
+const defaultPolicyReloadInterval = 30 * time.Second
+
+// maxParallelURLChecks bounds the lookups and shortener requests running at
+// once for a batch
+const maxParallelURLChecks = 16
+
+// newURLPolicy builds the destination policy from the URL_* env vars and
+// starts watching the lists file. A lists file that cannot be loaded is
+// fatal, running without the deny list would let blocked domains through.
+func newURLPolicy(db database.Service) *urlpolicy.Policy {
+	opts := urlpolicy.Options{
+		MaxLength: envInt("URL_MAX_LENGTH", urlpolicy.DefaultMaxLength),
+		ListsFile: os.Getenv("URL_POLICY_FILE"),
+		OwnHost:   ownHost(db),
+	}
+
+	for _, raw := range strings.Split(os.Getenv("URL_ALLOWED_PORTS"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		port, err := strconv.Atoi(raw)
+		if err != nil || port < 1 || port > 65535 {
+			log.Printf("[Config] ignoring invalid port %q in URL_ALLOWED_PORTS\n", raw)
+			continue
+		}
+		opts.AllowedPorts = append(opts.AllowedPorts, port)
+	}
+
+	resolve := true
+	if raw := os.Getenv("URL_RESOLVE_HOSTS"); raw != "" {
+		var err error
+		if resolve, err = strconv.ParseBool(raw); err != nil {
+			log.Printf("[Config] invalid URL_RESOLVE_HOSTS %q, resolving hosts\n", raw)
+			resolve = true
+		}
+	}
+	if resolve {
+		opts.Resolver = net.DefaultResolver
+	}
+
+	policy, err := urlpolicy.New(opts)
+	if err != nil {
+		log.Fatal("[URLPolicy] invalid url policy settings: ", err)
+	}
+
+	interval := defaultPolicyReloadInterval
+	if raw := os.Getenv("URL_POLICY_RELOAD_INTERVAL"); raw != "" {
+		if interval, err = time.ParseDuration(raw); err != nil || interval <= 0 {
+			log.Printf("[Config] invalid URL_POLICY_RELOAD_INTERVAL %q, using %s\n", raw, defaultPolicyReloadInterval)
+			interval = defaultPolicyReloadInterval
+		}
+	}
+	go policy.Watch(context.Background(), interval)
+
+	return policy
+}
+
+// ownHost matches the host of FRONTEND_URL and every branded domain, links
+// pointing at them would redirect back to us.
+func ownHost(db database.Service) func(host string) (bool, error) {
+	var frontendHost string
+	if uri, err := url.Parse(FRONTEND_URL); err == nil {
+		frontendHost = strings.ToLower(uri.Hostname())
+	}
+
+	return func(host string) (bool, error) {
+		if host == frontendHost {
+			return true, nil
+		}
+
+		_, err := db.GetDomain(host)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+}
+
+// newUnshortener returns nil unless URL_RESOLVE_SHORTENERS is set. Links to
+// SHORTENER_HOSTS (a comma separated list, well known shorteners by default)
+// are then followed for up to SHORTENER_MAX_HOPS redirects.
+func newUnshortener() *urlpolicy.Unshortener {
+	if enabled, _ := strconv.ParseBool(os.Getenv("URL_RESOLVE_SHORTENERS")); !enabled {
+		return nil
+	}
+
+	hosts := urlpolicy.DefaultShortenerHosts
+	if raw := os.Getenv("SHORTENER_HOSTS"); raw != "" {
+		hosts = strings.Split(raw, ",")
+	}
+
+	return urlpolicy.NewUnshortener(hosts, envInt("SHORTENER_MAX_HOPS", urlpolicy.DefaultMaxHops), nil)
+}
+
+// rejectionReason splits a policy error into its reason code and message.
+// Errors that are not a rejection are logged and reported without a code.
+func rejectionReason(err error) (string, string) {
+	var rejection *urlpolicy.Rejection
+	if errors.As(err, &rejection) {
+		return string(rejection.Reason), rejection.Message
+	}
+
+	log.Println("[CheckDestination] error occured while checking url", err)
+	return "", "error while checking url"
+}
+
+// checkDestination runs rawUrl through the url policy and answers with a 400
+// carrying the reason code when it is rejected. field names the offending
+// value in the message, e.g. "url" or "variant 2 url".
+func (s *Server) checkDestination(w http.ResponseWriter, r *http.Request, field string, rawUrl string) bool {
+	return s.writeDestinationError(w, field, s.urlPolicy.Check(r.Context(), rawUrl))
+}
+
+// writeDestinationError answers with the reason a destination was rejected,
+// it returns true when err is nil and nothing was written.
+func (s *Server) writeDestinationError(w http.ResponseWriter, field string, err error) bool {
+	if err == nil {
+		return true
+	}
+
+	var rejection *urlpolicy.Rejection
+	if !errors.As(err, &rejection) {
+		log.Println("[CheckDestination] error occured while checking url", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+
+	code, msg := rejectionReason(err)
+	writeJSONError(w, http.StatusBadRequest, code, fmt.Sprintf("%s: %s", field, msg))
+	return false
+}
+
+// linkTarget is where a link is going to point to.
+type linkTarget struct {
+	Url string
+	// OriginalUrl is the shortener url Url was resolved from
+	OriginalUrl *string
+}
+
+// linkDestination checks the url of a link. With the shortener resolver
+// enabled, urls of other shorteners are followed and the final target has
+// to pass the policy as well.
+func (s *Server) linkDestination(ctx context.Context, rawUrl string) (linkTarget, error) {
+	if err := s.urlPolicy.Check(ctx, rawUrl); err != nil {
+		return linkTarget{}, err
+	}
+
+	if s.unshortener == nil || !s.unshortener.IsShortener(rawUrl) {
+		return linkTarget{Url: rawUrl}, nil
+	}
+
+	finalUrl, err := s.unshortener.Resolve(ctx, rawUrl)
+	if err != nil {
+		return linkTarget{}, err
+	}
+	if finalUrl == rawUrl {
+		return linkTarget{Url: rawUrl}, nil
+	}
+
+	if err := s.urlPolicy.Check(ctx, finalUrl); err != nil {
+		return linkTarget{}, err
+	}
+
+	return linkTarget{Url: finalUrl, OriginalUrl: &rawUrl}, nil
+}
+
+// linkDestinations runs linkDestination for many urls at once.
+func (s *Server) linkDestinations(ctx context.Context, rawUrls []string) ([]linkTarget, []error) {
+	targets := make([]linkTarget, len(rawUrls))
+	errs := make([]error, len(rawUrls))
+	slots := make(chan struct{}, maxParallelURLChecks)
+
+	var wg sync.WaitGroup
+	for i, rawUrl := range rawUrls {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			targets[i], errs[i] = s.linkDestination(ctx, rawUrl)
+		}()
+	}
+	wg.Wait()
+
+	return targets, errs
+}
@@ -0,0 +1,302 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 2048
+
+	// qrLogoScale is the part of the QR code width covered by the logo, it
+	// has to stay within what the error correction can recover.
+	qrLogoScale = 0.22
+)
+
+// qrLevels maps the usual L/M/Q/H names to the recovery levels of go-qrcode,
+// which restore roughly 7%, 15%, 25% and 30% of the code.
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+type qrOptions struct {
+	svg        bool
+	size       int
+	level      qrcode.RecoveryLevel
+	foreground color.RGBA
+	background color.RGBA
+	logo       bool
+}
+
+// getLinkQR renders a QR code of the short url. The encoded url carries the
+// src=qr marker so scans show up as their own source in the analytics.
+func (s *Server) getLinkQR(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	opts, msg := parseQROptions(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if opts.logo && s.qrLogo == nil {
+		http.Error(w, "no QR logo is configured on this server", http.StatusBadRequest)
+		return
+	}
+
+	var logo image.Image
+	if opts.logo {
+		logo = s.qrLogo
+	}
+
+	content := shortURL(link.Domain, link.ShortCode) + "?src=qr"
+
+	var (
+		resp        []byte
+		contentType string
+		err         error
+	)
+	if opts.svg {
+		resp, err = renderQRSVG(content, opts, logo)
+		contentType = "image/svg+xml"
+	} else {
+		resp, err = renderQRPNG(content, opts, logo)
+		contentType = "image/png"
+	}
+	if err != nil {
+		log.Println("[GetLinkQR] Failed to render QR code", err)
+		http.Error(w, "failed to generate QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// parseQROptions reads the query parameters of the QR endpoint, a non empty
+// msg means they were rejected.
+func parseQROptions(query url.Values) (qrOptions, string) {
+	opts := qrOptions{
+		size:       defaultQRSize,
+		level:      qrcode.Medium,
+		foreground: color.RGBA{0, 0, 0, 0xff},
+		background: color.RGBA{0xff, 0xff, 0xff, 0xff},
+	}
+
+	switch strings.ToLower(query.Get("format")) {
+	case "", "png":
+	case "svg":
+		opts.svg = true
+	default:
+		return opts, "format must be png or svg"
+	}
+
+	if raw := query.Get("size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < minQRSize || size > maxQRSize {
+			return opts, fmt.Sprintf("size must be between %d and %d", minQRSize, maxQRSize)
+		}
+		opts.size = size
+	}
+
+	if raw := query.Get("logo"); raw != "" {
+		logo, err := strconv.ParseBool(raw)
+		if err != nil {
+			return opts, "logo must be true or false"
+		}
+		opts.logo = logo
+	}
+
+	// The logo hides the middle of the code, default to the strongest
+	// error correction and refuse the weak ones.
+	if opts.logo {
+		opts.level = qrcode.Highest
+	}
+
+	if raw := query.Get("level"); raw != "" {
+		level, ok := qrLevels[strings.ToUpper(raw)]
+		if !ok {
+			return opts, "level must be one of L, M, Q or H"
+		}
+		if opts.logo && level < qrcode.High {
+			return opts, "a logo needs error correction level Q or H"
+		}
+		opts.level = level
+	}
+
+	var ok bool
+	if raw := query.Get("fg"); raw != "" {
+		if opts.foreground, ok = parseHexColor(raw); !ok {
+			return opts, "fg must be a hex colour like 1a2b3c"
+		}
+	}
+	if raw := query.Get("bg"); raw != "" {
+		if opts.background, ok = parseHexColor(raw); !ok {
+			return opts, "bg must be a hex colour like ffffff"
+		}
+	}
+	if opts.foreground == opts.background {
+		return opts, "fg and bg must be different colours"
+	}
+
+	return opts, ""
+}
+
+// parseHexColor parses rrggbb or #rrggbb.
+func parseHexColor(raw string) (color.RGBA, bool) {
+	raw = strings.TrimPrefix(raw, "#")
+	if len(raw) != 6 {
+		return color.RGBA{}, false
+	}
+
+	value, err := strconv.ParseUint(raw, 16, 32)
+	if err != nil {
+		return color.RGBA{}, false
+	}
+
+	return color.RGBA{uint8(value >> 16), uint8(value >> 8), uint8(value), 0xff}, true
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func renderQRPNG(content string, opts qrOptions, logo image.Image) ([]byte, error) {
+	q, err := qrcode.New(content, opts.level)
+	if err != nil {
+		return nil, err
+	}
+	q.ForegroundColor = opts.foreground
+	q.BackgroundColor = opts.background
+
+	code := q.Image(opts.size)
+	if logo == nil {
+		var buf bytes.Buffer
+		err = png.Encode(&buf, code)
+		return buf.Bytes(), err
+	}
+
+	bounds := code.Bounds()
+	img := image.NewRGBA(bounds)
+	draw.Draw(img, bounds, code, bounds.Min, draw.Src)
+
+	// Centre the logo on a plain square so it does not blend into modules
+	logoSize := int(float64(bounds.Dx()) * qrLogoScale)
+	padding := logoSize / 10
+	offset := (bounds.Dx() - logoSize) / 2
+	plate := image.Rect(offset-padding, offset-padding, offset+logoSize+padding, offset+logoSize+padding)
+	draw.Draw(img, plate, image.NewUniform(opts.background), image.Point{}, draw.Src)
+
+	scaled := scaleImage(logo, logoSize)
+	draw.Draw(img, image.Rect(offset, offset, offset+logoSize, offset+logoSize), scaled, image.Point{}, draw.Over)
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+func renderQRSVG(content string, opts qrOptions, logo image.Image) ([]byte, error) {
+	q, err := qrcode.New(content, opts.level)
+	if err != nil {
+		return nil, err
+	}
+
+	// The bitmap includes the quiet zone, every module is one unit of the
+	// viewBox and the requested size only scales it.
+	bitmap := q.Bitmap()
+	modules := len(bitmap)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.size, opts.size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, modules, modules, hexColor(opts.background))
+
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hexColor(opts.foreground))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/>`)
+
+	if logo != nil {
+		var logoPNG bytes.Buffer
+		if err := png.Encode(&logoPNG, logo); err != nil {
+			return nil, err
+		}
+
+		logoSize := float64(modules) * qrLogoScale
+		padding := logoSize / 10
+		offset := (float64(modules) - logoSize) / 2
+
+		fmt.Fprintf(&buf, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"/>`,
+			offset-padding, offset-padding, logoSize+2*padding, logoSize+2*padding, hexColor(opts.background))
+		fmt.Fprintf(&buf, `<image x="%.2f" y="%.2f" width="%.2f" height="%.2f" href="data:image/png;base64,%s"/>`,
+			offset, offset, logoSize, logoSize, base64.StdEncoding.EncodeToString(logoPNG.Bytes()))
+	}
+
+	buf.WriteString(`</svg>`)
+
+	return buf.Bytes(), nil
+}
+
+// scaleImage resizes img to a size x size square with nearest neighbour
+// sampling, which is good enough for the small logo area.
+func scaleImage(img image.Image, size int) image.Image {
+	src := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dst.Set(x, y, img.At(src.Min.X+x*src.Dx()/size, src.Min.Y+y*src.Dy()/size))
+		}
+	}
+
+	return dst
+}
+
+// loadQRLogo reads the PNG or JPEG logo that can be placed in the middle of
+// QR codes. Without a path QR codes are only rendered without logo.
+func loadQRLogo(path string) image.Image {
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println("[LoadQRLogo] Failed to open logo, QR codes will have no logo", err)
+		return nil
+	}
+	defer f.Close()
+
+	logo, _, err := image.Decode(f)
+	if err != nil {
+		log.Println("[LoadQRLogo] Failed to decode logo, QR codes will have no logo", err)
+		return nil
+	}
+
+	return logo
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseQROptions(t *testing.T) {
+	opts, msg := parseQROptions(url.Values{"format": {"svg"}, "size": {"512"}, "fg": {"#1a2b3c"}})
+	if msg != "" {
+		t.Fatalf("unexpected rejection: %s", msg)
+	}
+	if !opts.svg || opts.size != 512 || opts.foreground != (color.RGBA{0x1a, 0x2b, 0x3c, 0xff}) {
+		t.Errorf("unexpected options %+v", opts)
+	}
+
+	for _, query := range []url.Values{
+		{"format": {"gif"}},
+		{"size": {"10"}},
+		{"level": {"X"}},
+		{"fg": {"red"}},
+		{"fg": {"ffffff"}},
+		{"logo": {"true"}, "level": {"L"}},
+	} {
+		if _, msg := parseQROptions(query); msg == "" {
+			t.Errorf("expected %v to be rejected", query)
+		}
+	}
+}
+
+func TestRenderQR(t *testing.T) {
+	opts, _ := parseQROptions(url.Values{"logo": {"true"}})
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	square := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			square.Set(x, y, red)
+		}
+	}
+
+	raw, err := renderQRPNG("http://localhost:3000/abc123?src=qr", opts, square)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() < defaultQRSize {
+		t.Errorf("expected at least %dpx; got %d", defaultQRSize, img.Bounds().Dx())
+	}
+	center := img.Bounds().Dx() / 2
+	if r, g, b, _ := img.At(center, center).RGBA(); r != 0xffff || g != 0 || b != 0 {
+		t.Error("expected the logo in the middle of the code")
+	}
+
+	svg, err := renderQRSVG("http://localhost:3000/abc123?src=qr", opts, square)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(svg), "<svg") || !strings.Contains(string(svg), "data:image/png;base64,") {
+		t.Errorf("unexpected svg %s", svg[:64])
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/scythe504/tiny-rl/internal"
+)
+
+// newTrustedProxies reads the comma separated IPs and CIDR ranges of
+// TRUSTED_PROXIES. Without it forwarding headers are ignored for rate
+// limits.
+func newTrustedProxies() []*net.IPNet {
+	proxies, err := internal.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatal("[Config] invalid TRUSTED_PROXIES: ", err)
+	}
+
+	return proxies
+}
+
+// failureLimiter counts failed attempts per key inside a sliding window and
+// blocks a key once it reached the limit.
+type failureLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	failures  map[string][]time.Time
+	lastSweep time.Time
+}
+
+func newFailureLimiter(limit int, window time.Duration) *failureLimiter {
+	return &failureLimiter{
+		limit:    limit,
+		window:   window,
+		failures: make(map[string][]time.Time),
+	}
+}
+
+// Blocked reports whether key has used up its attempts and, if so, how long
+// it has to wait before the oldest failure leaves the window.
+func (l *failureLimiter) Blocked(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := l.recent(key, now)
+	if len(recent) < l.limit {
+		return false, 0
+	}
+
+	return true, recent[0].Add(l.window).Sub(now)
+}
+
+// Fail records a failed attempt for key.
+func (l *failureLimiter) Fail(key string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.failures[key] = append(l.recent(key, now), now)
+
+	// Drop keys that went quiet so the map does not grow forever
+	if now.Sub(l.lastSweep) > l.window {
+		for k := range l.failures {
+			l.recent(k, now)
+		}
+		l.lastSweep = now
+	}
+}
+
+// Reset forgets all failures of key, used after a successful attempt.
+func (l *failureLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, key)
+}
+
+// recent returns the failures of key that are still inside the window.
+// The caller must hold l.mu.
+func (l *failureLimiter) recent(key string, now time.Time) []time.Time {
+	attempts, ok := l.failures[key]
+	if !ok {
+		return nil
+	}
+
+	i := 0
+	for i < len(attempts) && now.Sub(attempts[i]) >= l.window {
+		i++
+	}
+	attempts = attempts[i:]
+
+	if len(attempts) == 0 {
+		delete(l.failures, key)
+	} else {
+		l.failures[key] = attempts
+	}
+
+	return attempts
+}
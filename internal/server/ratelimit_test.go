@@ -0,0 +1,34 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFailureLimiter(t *testing.T) {
+	l := newFailureLimiter(3, time.Minute)
+	now := time.Now()
+
+	for i := range 3 {
+		if blocked, _ := l.Blocked("ip", now); blocked {
+			t.Fatalf("expected key not to be blocked after %d failures", i)
+		}
+		l.Fail("ip", now)
+	}
+
+	blocked, retryAfter := l.Blocked("ip", now.Add(10*time.Second))
+	if !blocked {
+		t.Fatal("expected key to be blocked after reaching the limit")
+	}
+	if retryAfter != 50*time.Second {
+		t.Errorf("expected retry after 50s; got %v", retryAfter)
+	}
+
+	if blocked, _ := l.Blocked("other-ip", now); blocked {
+		t.Error("expected other keys not to be affected")
+	}
+
+	if blocked, _ := l.Blocked("ip", now.Add(time.Minute)); blocked {
+		t.Error("expected failures to leave the window")
+	}
+}
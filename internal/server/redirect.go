@@ -0,0 +1,79 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+const defaultRedirectStatus = http.StatusFound
+
+// validRedirectStatus reports whether status is one of the redirect codes a
+// link may use.
+func validRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return true
+	}
+
+	return false
+}
+
+// parseRedirectStatus reads the server wide redirect status, e.g. from the
+// REDIRECT_STATUS env var, falling back to 302.
+func parseRedirectStatus(value string) int {
+	if value == "" {
+		return defaultRedirectStatus
+	}
+
+	status, err := strconv.Atoi(value)
+	if err != nil || !validRedirectStatus(status) {
+		log.Printf("[RedirectStatus] invalid redirect status %q, using %d\n", value, defaultRedirectStatus)
+		return defaultRedirectStatus
+	}
+
+	return status
+}
+
+// prefersJSON reports whether the client asked for the JSON response instead
+// of a redirect. Browsers navigating to a link always accept text/html.
+func prefersJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+// redirectStatusFor picks the status of a link, falling back to the server
+// default.
+func (s *Server) redirectStatusFor(link *database.LinkMap) int {
+	if link.RedirectStatus != nil {
+		return *link.RedirectStatus
+	}
+
+	if s.redirectStatus == 0 {
+		return defaultRedirectStatus
+	}
+
+	return s.redirectStatus
+}
+
+// redirectCacheControl returns the Cache-Control header of a redirect. Even
+// permanent redirects are only cached briefly and privately so that clicks
+// keep reaching us and get logged, links with limits are never cached.
+func redirectCacheControl(link *database.LinkMap, status int) string {
+	if link.ExpiresAt != nil || link.MaxClicks != nil || link.PasswordHash != "" {
+		return "no-store"
+	}
+
+	if status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect {
+		return "private, max-age=90"
+	}
+
+	return "private, no-cache"
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"log"
+	"os"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+	"github.com/scythe504/tiny-rl/internal/referrers"
+)
+
+// newReferrerRules loads the referrer categories from REFERRER_RULES_FILE,
+// the rules shipped in internal/referrers/rules.txt when it is not set.
+func newReferrerRules() *referrers.Rules {
+	path := os.Getenv("REFERRER_RULES_FILE")
+	if path == "" {
+		return referrers.DefaultRules()
+	}
+
+	rules, err := referrers.LoadRules(path)
+	if err != nil {
+		log.Fatal("[Config] invalid REFERRER_RULES_FILE: ", err)
+	}
+
+	return rules
+}
+
+// parseReferrerGrouping reads group_by of the referrer breakdown, it groups
+// by referrer unless domain or category is asked for.
+func parseReferrerGrouping(raw string) (database.ReferrerGrouping, string) {
+	switch grouping := database.ReferrerGrouping(raw); grouping {
+	case "", database.GroupByReferrer:
+		return database.GroupByReferrer, ""
+	case database.GroupByDomain, database.GroupByCategory:
+		return grouping, ""
+	default:
+		return "", "group_by must be one of referrer, domain or category"
+	}
+}
@@ -1,22 +1,25 @@
 package server
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/mileusna/useragent"
 	"github.com/scythe504/tiny-rl/internal"
 	"github.com/scythe504/tiny-rl/internal/database"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -34,17 +37,88 @@ func (s *Server) RegisterRoutes() http.Handler {
 
 	r.HandleFunc("/health", s.healthHandler)
 
-	r.HandleFunc("/api/shorten", s.shortenURL)
+	// Public like the redirect itself, so it is matched before the API
+	r.HandleFunc("/api/resolve/{shortCode:[a-zA-Z0-9_-]+}", s.resolveShortCode)
 
-	r.HandleFunc("/api/update-link", s.updateDestUrl)
+	api := r.PathPrefix("/api").Subrouter()
 
-	r.HandleFunc("/api/analytics/{shortCode}/days", s.getClicksAnalytics)
+	// Resolve the account behind the API key, if one was sent. Only the API
+	// looks at keys, short links stay public whatever headers are sent.
+	api.Use(s.authMiddleware)
 
-	r.HandleFunc("/api/analytics/{shortCode}/browsers", s.getBrowserAnalytics)
+	api.HandleFunc("/shorten", s.requireAccount(s.shortenURL))
 
-	r.HandleFunc("/api/analytics/{shortCode}/referrers", s.getReferrerAnalytics)
+	api.HandleFunc("/shorten/bulk", s.requireAccount(s.shortenBulk)).Methods(http.MethodPost, http.MethodOptions)
 
-	r.HandleFunc("/api/analytics/{shortCode}/countries", s.getCountryAnalytics)
+	api.HandleFunc("/update-link", s.requireAccount(s.updateDestUrl))
+
+	api.HandleFunc("/keys", s.requireAccount(s.listApiKeys)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/keys", s.requireAccount(s.createApiKey)).Methods(http.MethodPost, http.MethodOptions)
+
+	api.HandleFunc("/keys/{keyId:[0-9]+}", s.requireAccount(s.revokeApiKey)).Methods(http.MethodDelete, http.MethodOptions)
+
+	api.HandleFunc("/links", s.requireAccount(s.listLinks)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/tags", s.requireAccount(s.listTags)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/domains", s.requireAccount(s.listDomains)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}", s.requireLinkOwnerOrDeleted(s.deleteLink)).Methods(http.MethodDelete, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/restore", s.requireLinkOwnerOrDeleted(s.restoreLink)).Methods(http.MethodPost, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/disable", s.requireLinkOwner(s.disableLink)).Methods(http.MethodPost, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/enable", s.requireLinkOwner(s.enableLink)).Methods(http.MethodPost, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/revisions", s.requireLinkOwner(s.getLinkRevisions)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/revisions/{revision:[0-9]+}/rollback", s.requireLinkOwner(s.rollbackLink)).Methods(http.MethodPost, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/tags", s.requireLinkOwner(s.setLinkTags)).Methods(http.MethodPut, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/qr", s.requireLinkOwner(s.getLinkQR)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/geo-rules", s.requireLinkOwner(s.getGeoRules)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/geo-rules", s.requireLinkOwner(s.replaceGeoRules)).Methods(http.MethodPut, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/device-rules", s.requireLinkOwner(s.getDeviceRules)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/device-rules", s.requireLinkOwner(s.replaceDeviceRules)).Methods(http.MethodPut, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/variants", s.requireLinkOwner(s.getVariants)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/links/{shortCode}/variants", s.requireLinkOwner(s.replaceVariants)).Methods(http.MethodPut, http.MethodOptions)
+
+	api.HandleFunc("/analytics/domains", s.requireAccount(s.getDomainAnalytics))
+
+	api.HandleFunc("/analytics/export", s.requireAccount(s.exportAccountClicks)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/analytics/{shortCode}/export", s.requireLinkOwner(s.exportLinkClicks)).Methods(http.MethodGet, http.MethodOptions)
+
+	api.HandleFunc("/analytics/{shortCode}/summary", s.requireLinkOwner(s.getAnalyticsSummary))
+
+	api.HandleFunc("/analytics/{shortCode}/days", s.requireLinkOwner(s.getClicksAnalytics))
+
+	api.HandleFunc("/analytics/{shortCode}/browsers", s.requireLinkOwner(s.getBrowserAnalytics))
+
+	api.HandleFunc("/analytics/{shortCode}/os", s.requireLinkOwner(s.getOSAnalytics))
+
+	api.HandleFunc("/analytics/{shortCode}/devices", s.requireLinkOwner(s.getDeviceAnalytics))
+
+	api.HandleFunc("/analytics/{shortCode}/referrers", s.requireLinkOwner(s.getReferrerAnalytics))
+
+	api.HandleFunc("/analytics/{shortCode}/countries", s.requireLinkOwner(s.getCountryAnalytics))
+	api.HandleFunc("/analytics/{shortCode}/regions", s.requireLinkOwner(s.getRegionAnalytics))
+	api.HandleFunc("/analytics/{shortCode}/cities", s.requireLinkOwner(s.getCityAnalytics))
+
+	api.HandleFunc("/analytics/{shortCode}/revisions", s.requireLinkOwner(s.getRevisionAnalytics))
+
+	api.HandleFunc("/analytics/{shortCode}/variants", s.requireLinkOwner(s.getVariantAnalytics))
+
+	api.HandleFunc("/analytics/{shortCode}/sources", s.requireLinkOwner(s.getSourceAnalytics))
 
 	r.HandleFunc("/{shortCode:[a-zA-Z0-9_-]+}", s.getFullUrl)
 
@@ -57,7 +131,7 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 		// CORS Headers
 		w.Header().Set("Access-Control-Allow-Origin", "*") // Wildcard allows all origins
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-Original-Referrer")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-Link-Password, X-Original-Referrer")
 		w.Header().Set("Access-Control-Allow-Credentials", "false") // Credentials not allowed with wildcard origins
 
 		// Handle preflight OPTIONS requests
@@ -83,7 +157,16 @@ func (s *Server) HelloWorldHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
-	jsonResp, err := json.Marshal(s.db.Health())
+	health := s.db.Health()
+
+	// How crowded the keyspace of generated codes is
+	stats := s.codes.Stats()
+	health["shortcode_length"] = strconv.Itoa(stats.Length)
+	health["shortcode_generated"] = strconv.FormatUint(stats.Generated, 10)
+	health["shortcode_collisions"] = strconv.FormatUint(stats.Collisions, 10)
+	health["shortcode_collision_rate"] = strconv.FormatFloat(stats.CollisionRate(), 'f', 4, 64)
+
+	jsonResp, err := json.Marshal(health)
 
 	if err != nil {
 		log.Fatalf("error handling JSON marshal. Err: %v", err)
@@ -95,9 +178,6 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Server) shortenURL(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 
-	// TODO: LOAD IN FROM ENV VAR
-	frontendUrl := FRONTEND_URL
-
 	if err != nil {
 		log.Println("[ShortenURL] error while reading body: ", err)
 		http.Error(w, "error in reading the request body", http.StatusBadRequest)
@@ -106,7 +186,19 @@ func (s *Server) shortenURL(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	var link struct {
-		URL string `json:"url"`
+		URL       string     `json:"url"`
+		Alias     string     `json:"alias"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		MaxClicks *int       `json:"max_clicks"`
+		Password  string     `json:"password"`
+		// RedirectStatus is one of 301, 302, 307 or 308
+		RedirectStatus *int   `json:"redirect_status"`
+		Title          string `json:"title"`
+		Notes          string `json:"notes"`
+		// Private links get a long unguessable code instead of a short one
+		Private bool `json:"private"`
+		// Domain is the hostname of a branded domain, empty for FRONTEND_URL
+		Domain string `json:"domain"`
 	}
 
 	if err = json.Unmarshal(body, &link); err != nil {
@@ -115,46 +207,112 @@ func (s *Server) shortenURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !internal.ValidURL(link.URL) {
-		http.Error(w, "invalid url format, we do not support these format", http.StatusBadRequest)
+	target, err := s.linkDestination(r.Context(), link.URL)
+	if !s.writeDestinationError(w, "url", err) {
+		return
+	}
+
+	if link.Alias != "" && !internal.ValidAlias(link.Alias) {
+		http.Error(w, "invalid alias, use only letters, digits, '_' and '-' and avoid reserved paths", http.StatusBadRequest)
+		return
+	}
+
+	if msg := validateLinkLimits(link.ExpiresAt, link.MaxClicks); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if link.RedirectStatus != nil && !validRedirectStatus(*link.RedirectStatus) {
+		http.Error(w, "redirect_status must be one of 301, 302, 307 or 308", http.StatusBadRequest)
+		return
+	}
+
+	if msg := validateLinkDetails(link.Title, link.Notes); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	passwordHash, msg, err := hashLinkPassword(link.Password)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("[ShortenURL] error while hashing password ", err)
+		http.Error(w, "error while protecting link", http.StatusInternalServerError)
+		return
+	}
+
+	account := accountFromContext(r.Context())
+
+	domain, ok := s.linkDomain(w, account, link.Domain)
+	if !ok {
 		return
 	}
 
-	count := 0
-	shortCode := internal.ShortCode()
 	link_map := database.LinkMap{
-		ShortCode: shortCode,
-		Url:       link.URL,
+		DomainId:       domain.Id,
+		ShortCode:      link.Alias,
+		Url:            target.Url,
+		OriginalUrl:    target.OriginalUrl,
+		ExpiresAt:      link.ExpiresAt,
+		MaxClicks:      link.MaxClicks,
+		PasswordHash:   passwordHash,
+		OwnerId:        &account.Id,
+		RedirectStatus: link.RedirectStatus,
+		Title:          link.Title,
+		Notes:          link.Notes,
 	}
-	for {
-		if count > 5 {
-			http.Error(w, "error while generating short code", http.StatusInternalServerError)
-			return
-		}
-		err = s.db.InsertShortenedLink(link_map)
-		if err != nil {
-			var pgErr *pgconn.PgError
 
-			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
-				link_map.ShortCode = internal.ShortCode()
-				log.Println("[ShortenURL] duplicate key, retrying with new code")
-				count++
-				continue
+	if link.Alias != "" {
+		if err = s.db.InsertShortenedLink(link_map); err != nil {
+			if isUniqueViolation(err) {
+				http.Error(w, "alias is already taken", http.StatusConflict)
+				return
 			}
 
-			// It's some other error - don't retry
 			log.Println("[ShortenURL] database error: ", err)
 			http.Error(w, "database error", http.StatusInternalServerError)
 			return
-		} else {
-			break
+		}
+	} else {
+		codes := s.codes
+		if link.Private {
+			codes = s.privateCodes
+		}
+
+		count := 0
+		for {
+			if count > 5 {
+				http.Error(w, "error while generating short code", http.StatusInternalServerError)
+				return
+			}
+			link_map.ShortCode, err = codes.Next()
+			if err == nil {
+				err = s.db.InsertShortenedLink(link_map)
+			}
+			if err != nil {
+				if isUniqueViolation(err) {
+					codes.Collided()
+					log.Println("[ShortenURL] duplicate key, retrying with new code")
+					count++
+					continue
+				}
+
+				// It's some other error - don't retry
+				log.Println("[ShortenURL] database error: ", err)
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return
+			} else {
+				break
+			}
 		}
 	}
 
 	var resp = struct {
 		Data string `json:"data"`
 	}{
-		Data: fmt.Sprintf("%s/%s", frontendUrl, shortCode),
+		Data: shortURL(domain.Hostname, link_map.ShortCode),
 	}
 
 	jsonResp, err := json.Marshal(resp)
@@ -167,13 +325,26 @@ func (s *Server) shortenURL(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonResp)
 }
 
+// getFullUrl redirects to the destination of a short link, or answers with
+// JSON when the client prefers it through the Accept header.
 func (s *Server) getFullUrl(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	s.serveShortLink(w, r, prefersJSON(r))
+}
+
+// resolveShortCode always answers with JSON, for frontends doing the redirect
+// themselves.
+func (s *Server) resolveShortCode(w http.ResponseWriter, r *http.Request) {
+	s.serveShortLink(w, r, true)
+}
+
+func (s *Server) serveShortLink(w http.ResponseWriter, r *http.Request, asJSON bool) {
 	shCode := mux.Vars(r)["shortCode"]
 
-	linkMap, err := s.db.GetLink(shCode)
+	linkMap, err := s.db.GetLinkForHost(linkHost(r), shCode)
 	if err != nil {
-		switch err.Error() {
-		case pgx.ErrNoRows.Error():
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
 			http.Error(w, "short url is invalid", http.StatusNotFound)
 		default:
 			log.Println("[GetFullUrl] error occured while getting link", err)
@@ -182,67 +353,83 @@ func (s *Server) getFullUrl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	go func() {
-		userAgent := r.UserAgent()
-		referrer := r.Header.Get("X-Original-Referrer")
-		ua := useragent.Parse(userAgent)
-		browserName := ua.Name
-		if browserName == "" {
-			browserName = "Other"
-		}
-		if referrer == "" {
-			referrer = "direct"
-		}
+	if linkMap.DeletedAt != nil {
+		http.Error(w, "short url is invalid", http.StatusNotFound)
+		return
+	}
 
-		ipAddr := internal.GetClientIP(r)
-		parsedIP := net.ParseIP(ipAddr)
-		// parsedIP := net.ParseIP("8.8.8.8") // For testing geoip2 works fine or not
+	if linkMap.DisabledAt != nil {
+		http.Error(w, "short url has been disabled", http.StatusGone)
+		return
+	}
+
+	if linkMap.IsExpired(time.Now()) {
+		http.Error(w, "short url has expired", http.StatusGone)
+		return
+	}
 
-		geoIpCountry, err := s.geo_db.GetCountryByIP(parsedIP)
+	if linkMap.PasswordHash != "" && !s.checkLinkPassword(w, r, linkMap, asJSON) {
+		return
+	}
+
+	v := s.newVisit(r)
+
+	// Count the visit before logging it, a link that ran out of clicks in
+	// the meantime must not be served or recorded. Bots, e.g. link
+	// previews, do not use up the clicks of a link.
+	if !v.isBot() {
+		ok, err := s.db.IncrementClickCount(linkMap.Id)
 		if err != nil {
-			log.Println("[GoroutineLogClick] error parsing ipaddr", ipAddr, err)
+			log.Println("[GetFullUrl] error occured while counting click", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-
-		countryName := "India" // default fallback for local/dev
-		countryIsoCode := "IN" // default fallback
-
-		if geoIpCountry != nil && geoIpCountry.Country.IsoCode != "" {
-			name := geoIpCountry.Country.Names["en"]
-			if name != "" {
-				countryName = name
-			}
-			countryIsoCode = geoIpCountry.Country.IsoCode
+		if !ok {
+			http.Error(w, "short url has expired", http.StatusGone)
+			return
 		}
+	}
 
-		now := time.Now()
-
-		hashedIp := internal.HashIPWithDate(ipAddr, HASH_SALT, now)
+	dest := s.resolveDestination(linkMap, v)
 
-		click := database.Clicks{
-			ShortCode:      linkMap.ShortCode,
-			UserAgent:      userAgent,
-			Referrer:       referrer,
-			IpAddr:         hashedIp,
-			Browser:        browserName,
-			Country:        countryName,
-			CountryISOCode: countryIsoCode,
-			ClickedAt:      now,
+	go func() {
+		if v.geoErr != nil {
+			log.Println("[GoroutineLogClick] error parsing ipaddr", v.ipAddr, v.geoErr)
+			return
 		}
 
-		if err := s.db.LogClick(click); err != nil {
+		if err := s.db.LogClick(v.click(linkMap, dest)); err != nil {
 			log.Println("[GoroutineLogClick] error logging click:", err)
 		}
 	}()
 
+	if !asJSON && dest.fallbackUrl != "" && !isWebURL(dest.url) {
+		writeDeepLinkPage(w, dest)
+		return
+	}
+
+	if !asJSON {
+		status := s.redirectStatusFor(linkMap)
+		// The password form is posted, 307 and 308 would post the password
+		// on to the destination
+		if r.Method == http.MethodPost {
+			status = http.StatusSeeOther
+		}
+		w.Header().Set("Cache-Control", redirectCacheControl(linkMap, status))
+		http.Redirect(w, r, dest.url, status)
+		return
+	}
+
 	var resp map[string]any = make(map[string]any)
 
 	resp["data"] = struct {
-		ShortCode string `json:"short_code"`
-		Url       string `json:"url"`
+		ShortCode   string `json:"short_code"`
+		Url         string `json:"url"`
+		FallbackUrl string `json:"fallback_url,omitempty"`
 	}{
-		ShortCode: linkMap.ShortCode,
-		Url:       linkMap.Url,
+		ShortCode:   linkMap.ShortCode,
+		Url:         dest.url,
+		FallbackUrl: dest.fallbackUrl,
 	}
 
 	jsonResp, err := json.Marshal(resp)
@@ -251,14 +438,25 @@ func (s *Server) getFullUrl(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to redirect to url", http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonResp)
 }
 
 func (s *Server) getClicksAnalytics(w http.ResponseWriter, r *http.Request) {
-	shortCode := mux.Vars(r)["shortCode"]
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if !s.checkTimeline(w, link.Id, rng) {
+		return
+	}
 
-	clicksOverTime, err := s.db.GetClicksOverTime(shortCode)
+	clicksOverTime, err := s.db.GetClicksOverTime(link.Id, rng)
 	if err != nil {
 		switch err {
 		case pgx.ErrNoRows:
@@ -284,9 +482,15 @@ func (s *Server) getClicksAnalytics(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) getBrowserAnalytics(w http.ResponseWriter, r *http.Request) {
-	shortCode := mux.Vars(r)["shortCode"]
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
 
-	clicksPerBrowser, err := s.db.GetBrowserStats(shortCode)
+	clicksPerBrowser, err := s.db.GetBrowserStats(link.Id, rng)
 	if err != nil {
 		switch err {
 		case pgx.ErrNoRows:
@@ -311,10 +515,90 @@ func (s *Server) getBrowserAnalytics(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonResp)
 }
 
+func (s *Server) getOSAnalytics(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	clicksPerOS, err := s.db.GetOSStats(link.Id, rng)
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Println("[GetOSAnalytics] No one has clicked this link", err)
+			http.Error(w, "No data has been captured for this short link", http.StatusNoContent)
+		default:
+			log.Println("[GetOSAnalytics] Some error occured: ", err)
+			http.Error(w, "Some error occured, please check if the short link is valid, or try again later", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	jsonResp, err := json.Marshal(clicksPerOS)
+
+	if err != nil {
+		log.Println("[GetOSAnalytics] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+func (s *Server) getDeviceAnalytics(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	clicksPerDevice, err := s.db.GetDeviceStats(link.Id, rng)
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Println("[GetDeviceAnalytics] No one has clicked this link", err)
+			http.Error(w, "No data has been captured for this short link", http.StatusNoContent)
+		default:
+			log.Println("[GetDeviceAnalytics] Some error occured: ", err)
+			http.Error(w, "Some error occured, please check if the short link is valid, or try again later", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	jsonResp, err := json.Marshal(clicksPerDevice)
+
+	if err != nil {
+		log.Println("[GetDeviceAnalytics] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
 func (s *Server) getReferrerAnalytics(w http.ResponseWriter, r *http.Request) {
-	shortCode := mux.Vars(r)["shortCode"]
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	groupBy, msg := parseReferrerGrouping(r.URL.Query().Get("group_by"))
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
 
-	trafficFromReferrers, err := s.db.GetReferrerStats(shortCode)
+	trafficFromReferrers, err := s.db.GetReferrerStats(link.Id, rng, groupBy)
 	if err != nil {
 		switch err {
 		case pgx.ErrNoRows:
@@ -340,9 +624,15 @@ func (s *Server) getReferrerAnalytics(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) getCountryAnalytics(w http.ResponseWriter, r *http.Request) {
-	shortCode := mux.Vars(r)["shortCode"]
+	link := linkFromContext(r.Context())
 
-	trafficFromCountries, err := s.db.GetCountryStats(shortCode)
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	trafficFromCountries, err := s.db.GetCountryStats(link.Id, rng)
 	if err != nil {
 		switch err {
 		case pgx.ErrNoRows:
@@ -367,6 +657,176 @@ func (s *Server) getCountryAnalytics(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonResp)
 }
 
+func (s *Server) getRegionAnalytics(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	clicksPerRegions, err := s.db.GetRegionStats(link.Id, rng)
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Println("[GetRegionAnalytics] No one has clicked this link", err)
+			http.Error(w, "No data has been captured for this short link", http.StatusNoContent)
+		default:
+			log.Println("[GetRegionAnalytics] Some error occured: ", err)
+			http.Error(w, "Some error occured, please check if the short link is valid, or try again later", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	jsonResp, err := json.Marshal(clicksPerRegions)
+
+	if err != nil {
+		log.Println("[GetRegionAnalytics] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+func (s *Server) getCityAnalytics(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	clicksPerCities, err := s.db.GetCityStats(link.Id, rng)
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Println("[GetCityAnalytics] No one has clicked this link", err)
+			http.Error(w, "No data has been captured for this short link", http.StatusNoContent)
+		default:
+			log.Println("[GetCityAnalytics] Some error occured: ", err)
+			http.Error(w, "Some error occured, please check if the short link is valid, or try again later", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	jsonResp, err := json.Marshal(clicksPerCities)
+
+	if err != nil {
+		log.Println("[GetCityAnalytics] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+func (s *Server) getRevisionAnalytics(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	clicksPerRevision, err := s.db.GetRevisionStats(link.Id, rng)
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Println("[GetRevisionAnalytics] No one has clicked this link", err)
+			http.Error(w, "No data has been captured for this short link", http.StatusNoContent)
+		default:
+			log.Println("[GetRevisionAnalytics] Some error occured: ", err)
+			http.Error(w, "Some error occured, please check if the short link is valid, or try again later", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	jsonResp, err := json.Marshal(clicksPerRevision)
+
+	if err != nil {
+		log.Println("[GetRevisionAnalytics] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+func (s *Server) getVariantAnalytics(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	clicksPerVariant, err := s.db.GetVariantStats(link.Id, rng)
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Println("[GetVariantAnalytics] No one has clicked this link", err)
+			http.Error(w, "No data has been captured for this short link", http.StatusNoContent)
+		default:
+			log.Println("[GetVariantAnalytics] Some error occured: ", err)
+			http.Error(w, "Some error occured, please check if the short link is valid, or try again later", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	jsonResp, err := json.Marshal(clicksPerVariant)
+
+	if err != nil {
+		log.Println("[GetVariantAnalytics] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+func (s *Server) getSourceAnalytics(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+
+	rng, msg := parseClickRange(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	clicksPerSource, err := s.db.GetSourceStats(link.Id, rng)
+	if err != nil {
+		switch err {
+		case pgx.ErrNoRows:
+			log.Println("[GetSourceAnalytics] No one has clicked this link", err)
+			http.Error(w, "No data has been captured for this short link", http.StatusNoContent)
+		default:
+			log.Println("[GetSourceAnalytics] Some error occured: ", err)
+			http.Error(w, "Some error occured, please check if the short link is valid, or try again later", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	jsonResp, err := json.Marshal(clicksPerSource)
+
+	if err != nil {
+		log.Println("[GetSourceAnalytics] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
 func (s *Server) updateDestUrl(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 
@@ -377,7 +837,18 @@ func (s *Server) updateDestUrl(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	var link_map database.LinkMap
+	var link_map struct {
+		ShortCode string     `json:"short_code"`
+		Domain    string     `json:"domain"`
+		Url       string     `json:"url"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		MaxClicks *int       `json:"max_clicks"`
+		Password  *string    `json:"password"`
+		// RedirectStatus is one of 301, 302, 307 or 308
+		RedirectStatus *int    `json:"redirect_status"`
+		Title          *string `json:"title"`
+		Notes          *string `json:"notes"`
+	}
 
 	if err = json.Unmarshal(body, &link_map); err != nil {
 		log.Println("[UpdateDestinationUrl] Invalid request body", err)
@@ -385,13 +856,110 @@ func (s *Server) updateDestUrl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !internal.ValidURL(link_map.Url) {
-		http.Error(w, "invalid url", http.StatusBadRequest)
+	// Keep track of which limits were sent, an explicit null removes a limit
+	// while a missing field leaves it untouched.
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(body, &fields); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	_, hasExpiry := fields["expires_at"]
+	_, hasMaxClicks := fields["max_clicks"]
+	_, hasPassword := fields["password"]
+	_, hasRedirectStatus := fields["redirect_status"]
+	_, hasTitle := fields["title"]
+	_, hasNotes := fields["notes"]
+
+	if link_map.Url == "" && !hasExpiry && !hasMaxClicks && !hasPassword && !hasRedirectStatus && !hasTitle && !hasNotes {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	current, ok := s.ownedLink(w, r, link_map.Domain, link_map.ShortCode, false)
+	if !ok {
+		return
+	}
+
+	var target linkTarget
+	if link_map.Url != "" {
+		target, err = s.linkDestination(r.Context(), link_map.Url)
+		if !s.writeDestinationError(w, "url", err) {
+			return
+		}
+	}
+
+	// Only validate the limits that are being changed, the stored expiry
+	// may already lie in the past.
+	if msg := validateLinkLimits(link_map.ExpiresAt, link_map.MaxClicks); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if link_map.RedirectStatus != nil && !validRedirectStatus(*link_map.RedirectStatus) {
+		http.Error(w, "redirect_status must be one of 301, 302, 307 or 308", http.StatusBadRequest)
+		return
+	}
+
+	// Title and notes are updated together, keep the stored value of the
+	// one that was not sent. A null clears it.
+	title, notes := current.Title, current.Notes
+	if hasTitle {
+		title = ""
+		if link_map.Title != nil {
+			title = *link_map.Title
+		}
+	}
+	if hasNotes {
+		notes = ""
+		if link_map.Notes != nil {
+			notes = *link_map.Notes
+		}
+	}
+
+	if msg := validateLinkDetails(title, notes); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
 
-	if err = s.db.UpdateShortenedLink(link_map.ShortCode, link_map.Url); err != nil {
-		log.Println("[UpdateDestinationUrl] Failed to update destination", err)
+	// An explicit null or empty password removes the protection
+	var passwordHash string
+	if link_map.Password != nil {
+		var msg string
+		passwordHash, msg, err = hashLinkPassword(*link_map.Password)
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Println("[UpdateDestinationUrl] Failed to hash password", err)
+			http.Error(w, "failed to update destination", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	update := database.LinkUpdate{
+		Url:               target.Url,
+		OriginalUrl:       target.OriginalUrl,
+		SetLimits:         hasExpiry || hasMaxClicks,
+		ExpiresAt:         current.ExpiresAt,
+		MaxClicks:         current.MaxClicks,
+		SetPassword:       hasPassword,
+		PasswordHash:      passwordHash,
+		SetRedirectStatus: hasRedirectStatus,
+		RedirectStatus:    link_map.RedirectStatus,
+		SetDetails:        hasTitle || hasNotes,
+		Title:             title,
+		Notes:             notes,
+	}
+	if hasExpiry {
+		update.ExpiresAt = link_map.ExpiresAt
+	}
+	if hasMaxClicks {
+		update.MaxClicks = link_map.MaxClicks
+	}
+
+	if err = s.db.UpdateLink(current.Id, update); err != nil {
+		log.Println("[UpdateDestinationUrl] Failed to update link", err)
 		http.Error(w, "failed to update destination", http.StatusInternalServerError)
 		return
 	}
@@ -399,3 +967,134 @@ func (s *Server) updateDestUrl(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("{\"message\": \"success\"}"))
 }
+
+// isUniqueViolation reports whether err is a postgres unique_violation (23505).
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// validateLinkLimits checks the optional expiry settings of a link and returns
+// a message describing the problem, or an empty string when they are valid.
+func validateLinkLimits(expiresAt *time.Time, maxClicks *int) string {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "expires_at must be in the future"
+	}
+
+	if maxClicks != nil && *maxClicks < 1 {
+		return "max_clicks must be at least 1"
+	}
+
+	return ""
+}
+
+const (
+	maxTitleLength = 200
+	maxNotesLength = 2000
+)
+
+// validateLinkDetails checks the title and notes of a link and returns a
+// message describing the problem, or an empty string when they are valid.
+func validateLinkDetails(title string, notes string) string {
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return fmt.Sprintf("title must be at most %d characters", maxTitleLength)
+	}
+
+	if utf8.RuneCountInString(notes) > maxNotesLength {
+		return fmt.Sprintf("notes must be at most %d characters", maxNotesLength)
+	}
+
+	return ""
+}
+
+// writeJSONError sends an error with a machine-readable code that clients can
+// act upon, e.g. prompting for a password.
+func writeJSONError(w http.ResponseWriter, status int, code string, message string) {
+	jsonResp, err := json.Marshal(map[string]string{
+		"error":   code,
+		"message": message,
+	})
+	if err != nil {
+		http.Error(w, message, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonResp)
+}
+
+// hashLinkPassword hashes a link password with bcrypt. An empty password yields
+// an empty hash, a non empty msg means the password was rejected.
+func hashLinkPassword(password string) (hash string, msg string, err error) {
+	if password == "" {
+		return "", "", nil
+	}
+
+	// bcrypt only looks at the first 72 bytes
+	if len(password) < 4 || len(password) > 72 {
+		return "", "password must be between 4 and 72 characters", nil
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(hashed), "", nil
+}
+
+// checkLinkPassword verifies the X-Link-Password header, or the password
+// field of the form served to browsers following the redirect, for a
+// protected link. It writes the error response, JSON or the password form,
+// and returns false when access is denied.
+func (s *Server) checkLinkPassword(w http.ResponseWriter, r *http.Request, link *database.LinkMap, asJSON bool) bool {
+	deny := func(status int, code string, message string) {
+		if asJSON {
+			writeJSONError(w, status, code, message)
+			return
+		}
+		writePasswordPage(w, status, message)
+	}
+
+	now := time.Now()
+	// Headers the client can set itself must not pick the key, only those
+	// of trusted proxies are believed
+	ipAddr := internal.RemoteIP(r, s.trustedProxies)
+
+	// Without a salt HashIPWithDate returns nothing, fall back to the raw
+	// address which only ever lives in memory. Attempts are counted per
+	// link, so getting one link right does not reset the others.
+	key := internal.HashIPWithDate(ipAddr, HASH_SALT, now)
+	if key == "" {
+		key = ipAddr
+	}
+	key += ":" + strconv.Itoa(link.Id)
+
+	if blocked, retryAfter := s.passwordLimiter.Blocked(key, now); blocked {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		deny(http.StatusTooManyRequests, "too_many_attempts", "too many wrong passwords, try again later")
+		return false
+	}
+
+	password := r.Header.Get("X-Link-Password")
+	if password == "" && r.Method == http.MethodPost {
+		password = r.PostFormValue("password")
+	}
+	if password == "" {
+		deny(http.StatusUnauthorized, "password_required", "this link is password protected")
+		return false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)); err != nil {
+		s.passwordLimiter.Fail(key, now)
+		deny(http.StatusUnauthorized, "invalid_password", "the password is incorrect")
+		return false
+	}
+
+	// A visitor who got it right is not held back by earlier typos on
+	// this link
+	s.passwordLimiter.Reset(key)
+
+	return true
+}
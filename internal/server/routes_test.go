@@ -4,7 +4,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestHandler(t *testing.T) {
@@ -29,3 +34,45 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected response body to be %v; got %v", expected, string(body))
 	}
 }
+
+func TestAuthOnlyOnAPI(t *testing.T) {
+	s := &Server{}
+	handler := s.RegisterRoutes()
+
+	cases := map[string]int{
+		"/":          http.StatusOK,
+		"/api/links": http.StatusUnauthorized,
+	}
+	for path, expected := range cases {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != expected {
+			t.Errorf("expected %s to answer %d, got %d", path, expected, rec.Code)
+		}
+	}
+}
+
+func TestCheckLinkPasswordForm(t *testing.T) {
+	s := &Server{passwordLimiter: newFailureLimiter(5, time.Minute)}
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	link := &database.LinkMap{Id: 1, PasswordHash: string(hash)}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	if s.checkLinkPassword(rec, req, link, false) {
+		t.Fatal("expected a missing password to be denied")
+	}
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `<form method="post">`) {
+		t.Errorf("expected the password form; got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/abc", strings.NewReader("password=secret"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if !s.checkLinkPassword(rec, req, link, false) {
+		t.Errorf("expected the posted password to be accepted; got %d", rec.Code)
+	}
+}
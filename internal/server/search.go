@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+	maxTagsPerLink   = 20
+)
+
+// tagPattern matches tag names after they were lowercased
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// listLinks returns the links of the calling account, newest first by
+// default. Filters are combined, the next page is requested by passing the
+// returned next_cursor back as cursor.
+func (s *Server) listLinks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	params := database.ListLinksParams{
+		OwnerId: accountFromContext(r.Context()).Id,
+		Tag:     strings.ToLower(strings.TrimSpace(query.Get("tag"))),
+		Domain:  strings.TrimSpace(query.Get("domain")),
+		Query:   strings.TrimSpace(query.Get("q")),
+		Limit:   defaultListLimit,
+	}
+
+	switch query.Get("sort") {
+	case "", "created_at":
+		params.Sort = database.SortByCreated
+	case "clicks":
+		params.Sort = database.SortByClicks
+	default:
+		http.Error(w, "sort must be created_at or clicks", http.StatusBadRequest)
+		return
+	}
+
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		params.Ascending = true
+	default:
+		http.Error(w, "order must be asc or desc", http.StatusBadRequest)
+		return
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		params.Limit = limit
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := decodeLinkCursor(raw)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		params.After = cursor
+	}
+
+	links, next, err := s.db.ListLinks(params)
+	if err != nil {
+		log.Println("[ListLinks] Some error occured: ", err)
+		http.Error(w, "failed to list links", http.StatusInternalServerError)
+		return
+	}
+
+	var resp = struct {
+		Data       []database.LinkMap `json:"data"`
+		NextCursor string             `json:"next_cursor,omitempty"`
+	}{
+		Data: links,
+	}
+
+	if next != nil {
+		if resp.NextCursor, err = encodeLinkCursor(next); err != nil {
+			log.Println("[ListLinks] Error while encoding cursor", err)
+			http.Error(w, "failed to send data", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	jsonResp, err := json.Marshal(resp)
+	if err != nil {
+		log.Println("[ListLinks] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	account := accountFromContext(r.Context())
+
+	tags, err := s.db.GetTags(account.Id)
+	if err != nil {
+		log.Println("[ListTags] Some error occured: ", err)
+		http.Error(w, "failed to list tags", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResp, err := json.Marshal(tags)
+	if err != nil {
+		log.Println("[ListTags] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+// setLinkTags replaces all tags of a link, an empty list removes them.
+func (s *Server) setLinkTags(w http.ResponseWriter, r *http.Request) {
+	link := linkFromContext(r.Context())
+	account := accountFromContext(r.Context())
+
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println("[SetLinkTags] Invalid request body", err)
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	tags, msg := normalizeTags(body.Tags)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.SetLinkTags(account.Id, link.Id, tags); err != nil {
+		log.Println("[SetLinkTags] Failed to update tags", err)
+		http.Error(w, "failed to update tags", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResp, err := json.Marshal(map[string][]string{"tags": tags})
+	if err != nil {
+		log.Println("[SetLinkTags] Error while Marshaling data", err)
+		http.Error(w, "failed to send data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResp)
+}
+
+// normalizeTags lowercases, dedupes and sorts tag names. A non empty msg
+// means a tag was rejected.
+func normalizeTags(names []string) ([]string, string) {
+	seen := make(map[string]bool, len(names))
+	tags := make([]string, 0, len(names))
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !tagPattern.MatchString(name) {
+			return nil, fmt.Sprintf("invalid tag %q, use up to 64 letters, digits, '_' and '-'", name)
+		}
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		tags = append(tags, name)
+	}
+
+	if len(tags) > maxTagsPerLink {
+		return nil, fmt.Sprintf("a link can have at most %d tags", maxTagsPerLink)
+	}
+
+	sort.Strings(tags)
+	return tags, ""
+}
+
+// Cursors are opaque to clients, the encoding may change at any time.
+func encodeLinkCursor(cursor *database.LinkCursor) (string, error) {
+	raw, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeLinkCursor(encoded string) (*database.LinkCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor database.LinkCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Id == 0 {
+		return nil, fmt.Errorf("cursor without link id")
+	}
+
+	return &cursor, nil
+}
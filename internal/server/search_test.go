@@ -0,0 +1,52 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, msg := normalizeTags([]string{" Launch ", "q3", "launch", "a_b-c"})
+	if msg != "" {
+		t.Fatalf("unexpected rejection: %s", msg)
+	}
+
+	expected := []string{"a_b-c", "launch", "q3"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected %v; got %v", expected, tags)
+	}
+
+	for _, invalid := range []string{"", "-dash", "has space", "emoji✨"} {
+		if _, msg := normalizeTags([]string{invalid}); msg == "" {
+			t.Errorf("expected tag %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestLinkCursorRoundTrip(t *testing.T) {
+	cursor := &database.LinkCursor{
+		CreatedAt:  time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC),
+		ClickCount: 42,
+		Id:         7,
+	}
+
+	encoded, err := encodeLinkCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := decodeLinkCursor(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ClickCount != 42 || decoded.Id != 7 {
+		t.Errorf("expected %+v; got %+v", cursor, decoded)
+	}
+
+	if _, err := decodeLinkCursor("not a cursor"); err == nil {
+		t.Error("expected invalid cursor to be rejected")
+	}
+}
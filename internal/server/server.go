@@ -2,6 +2,8 @@ package server
 
 import (
 	"fmt"
+	"image"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
@@ -9,22 +11,82 @@ import (
 
 	_ "github.com/joho/godotenv/autoload"
 
+	"github.com/scythe504/tiny-rl/internal/bots"
 	"github.com/scythe504/tiny-rl/internal/database"
+	"github.com/scythe504/tiny-rl/internal/export"
 	"github.com/scythe504/tiny-rl/internal/geodatabase"
+	"github.com/scythe504/tiny-rl/internal/referrers"
+	"github.com/scythe504/tiny-rl/internal/shortcode"
+	"github.com/scythe504/tiny-rl/internal/urlpolicy"
 )
 
 type Server struct {
 	port   int
 	geo_db geodatabase.Service
 	db     database.Service
+
+	// codes generates the short codes of new links, privateCodes the long
+	// unguessable ones of private links
+	codes        shortcode.Generator
+	privateCodes shortcode.Generator
+
+	// redirectStatus is used for links without their own redirect status
+	redirectStatus int
+
+	// passwordLimiter throttles wrong passwords for protected links per
+	// link and hashed IP
+	passwordLimiter *failureLimiter
+	// trustedProxies may set X-Forwarded-For for the addresses the password
+	// limiter goes by
+	trustedProxies []*net.IPNet
+
+	// qrLogo is placed in the middle of QR codes on request, nil when
+	// QR_LOGO_PATH is not set
+	qrLogo image.Image
+
+	// urlPolicy decides which destinations may be shortened
+	urlPolicy *urlpolicy.Policy
+	// unshortener follows links of other shorteners to their final target,
+	// nil when URL_RESOLVE_SHORTENERS is off
+	unshortener *urlpolicy.Unshortener
+
+	// bots tells clicks of people from those of crawlers and monitoring
+	bots *bots.Classifier
+	// referrers sorts referrers into search, social, email and other
+	referrers *referrers.Rules
+
+	// exportPrivacy decides which fields of raw click exports are left out
+	// or pseudonymized
+	exportPrivacy *export.Privacy
 }
 
 func NewServer() *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
+	db := database.New()
+	codes, privateCodes := newCodeGenerators(db)
+
 	NewServer := &Server{
 		port:   port,
 		geo_db: geodatabase.New(),
-		db:     database.New(),
+		db:     db,
+
+		codes:        codes,
+		privateCodes: privateCodes,
+
+		redirectStatus: parseRedirectStatus(os.Getenv("REDIRECT_STATUS")),
+
+		passwordLimiter: newFailureLimiter(5, 15*time.Minute),
+		trustedProxies:  newTrustedProxies(),
+
+		qrLogo: loadQRLogo(os.Getenv("QR_LOGO_PATH")),
+
+		urlPolicy:   newURLPolicy(db),
+		unshortener: newUnshortener(),
+
+		bots:      newBotClassifier(),
+		referrers: newReferrerRules(),
+
+		exportPrivacy: newExportPrivacy(),
 	}
 
 	// Declare Server config
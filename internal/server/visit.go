@@ -0,0 +1,171 @@
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mileusna/useragent"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/scythe504/tiny-rl/internal"
+	"github.com/scythe504/tiny-rl/internal/database"
+	"github.com/scythe504/tiny-rl/internal/referrers"
+)
+
+// visit is what we know about a client following a short link. It is built
+// before answering so destination rules can use it, and logged afterwards.
+type visit struct {
+	userAgent string
+	ua        useragent.UserAgent
+	referrer  referrers.Referrer
+	ipAddr    string
+	geo       *geoip2.Country
+	geoErr    error
+	source    string
+	at        time.Time
+	// bot is set for crawlers, link previews and monitoring
+	bot bool
+	// city is nil without the GeoLite2-City database
+	city *geoip2.City
+}
+
+// clickSources are the values of the src query parameter that are stored
+// with a click, anything else counts as a plain link click.
+var clickSources = map[string]bool{
+	"qr": true,
+}
+
+func (s *Server) newVisit(r *http.Request) *visit {
+	userAgent := r.UserAgent()
+
+	v := &visit{
+		userAgent: userAgent,
+		ua:        useragent.Parse(userAgent),
+		referrer:  s.referrers.Classify(r.Header.Get("X-Original-Referrer")),
+		ipAddr:    internal.GetClientIP(r),
+		at:        time.Now(),
+	}
+
+	if src := r.URL.Query().Get("src"); clickSources[src] {
+		v.source = src
+	}
+
+	parsedIP := net.ParseIP(v.ipAddr)
+	// parsedIP := net.ParseIP("8.8.8.8") // For testing geoip2 works fine or not
+
+	v.geo, v.geoErr = s.geo_db.GetCountryByIP(parsedIP)
+	// Failed city lookups are logged by geodatabase, the click keeps its
+	// country
+	v.city, _ = s.geo_db.GetCityByIP(parsedIP)
+	v.bot = s.bots.IsBot(userAgent, v.ua, parsedIP)
+
+	return v
+}
+
+// isBot reports whether the visitor is a bot, by its user agent or the
+// configured bot rules.
+func (v *visit) isBot() bool {
+	return v.ua.Bot || v.bot
+}
+
+// countryISOCode returns the country of the visitor, or "" when it is not
+// known. Unlike the logged value it has no local/dev fallback.
+func (v *visit) countryISOCode() string {
+	if v.geo == nil {
+		return ""
+	}
+
+	return v.geo.Country.IsoCode
+}
+
+// stickyKey identifies the visitor for the rest of the day, it is the same
+// daily salted hash that ends up in clicks.ip_addr.
+func (v *visit) stickyKey() string {
+	return internal.HashIPWithDate(v.ipAddr, HASH_SALT, v.at)
+}
+
+// click builds the row logged for this visit.
+func (v *visit) click(link *database.LinkMap, dest destination) database.Clicks {
+	browserName := v.ua.Name
+	if browserName == "" {
+		browserName = "Other"
+	}
+
+	osName := v.ua.OS
+	if osName == "" {
+		osName = "Other"
+	}
+
+	device := deviceType(v.ua)
+	switch {
+	case v.isBot():
+		device = "bot"
+	case device == "":
+		device = "other"
+	}
+
+	countryName := "India" // default fallback for local/dev
+	countryIsoCode := "IN" // default fallback
+
+	if v.geo != nil && v.geo.Country.IsoCode != "" {
+		name := v.geo.Country.Names["en"]
+		if name != "" {
+			countryName = name
+		}
+		countryIsoCode = v.geo.Country.IsoCode
+	}
+
+	click := database.Clicks{
+		LinkId:           link.Id,
+		UserAgent:        v.userAgent,
+		Referrer:         v.referrer.Referrer,
+		RawReferrer:      v.referrer.Raw,
+		ReferrerDomain:   v.referrer.Domain,
+		ReferrerCategory: string(v.referrer.Category),
+		IpAddr:           v.stickyKey(),
+		Browser:          browserName,
+		BrowserVersion:   v.ua.Version,
+		OS:               osName,
+		OSVersion:        v.ua.OSVersion,
+		Device:           v.ua.Device,
+		DeviceType:       device,
+		Country:          countryName,
+		CountryISOCode:   countryIsoCode,
+		ClickedAt:        v.at,
+		Revision:         link.Revision,
+		GeoRuleId:        dest.geoRuleId,
+		DeviceRuleId:     dest.deviceRuleId,
+		VariantId:        dest.variantId,
+		Source:           v.source,
+		IsBot:            v.isBot(),
+	}
+	v.locate(&click)
+
+	return click
+}
+
+// coordinateScale rounds coordinates to one decimal, about 10 km, which is
+// all city analytics need and keeps visitors from being pinpointed.
+const coordinateScale = 10
+
+// locate adds the subdivision, city and rounded coordinates of the visitor
+// when the City database knows them.
+func (v *visit) locate(click *database.Clicks) {
+	if v.city == nil {
+		return
+	}
+
+	if len(v.city.Subdivisions) > 0 {
+		click.Subdivision = v.city.Subdivisions[0].Names["en"]
+		click.SubdivisionISOCode = v.city.Subdivisions[0].IsoCode
+	}
+	click.City = v.city.City.Names["en"]
+
+	// 0, 0 is what the database has for unknown coordinates
+	if location := v.city.Location; location.Latitude != 0 || location.Longitude != 0 {
+		latitude := math.Round(location.Latitude*coordinateScale) / coordinateScale
+		longitude := math.Round(location.Longitude*coordinateScale) / coordinateScale
+		click.Latitude, click.Longitude = &latitude, &longitude
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mileusna/useragent"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/scythe504/tiny-rl/internal/database"
+)
+
+func TestVisitClickDevice(t *testing.T) {
+	cases := []struct {
+		name       string
+		userAgent  string
+		os         string
+		deviceType string
+	}{
+		{"android phone", "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Mobile Safari/537.36", useragent.Android, "mobile"},
+		{"desktop", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36", useragent.Windows, "desktop"},
+		{"crawler", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Other", "bot"},
+		{"empty", "", "Other", "other"},
+	}
+
+	for _, c := range cases {
+		v := &visit{userAgent: c.userAgent, ua: useragent.Parse(c.userAgent)}
+		click := v.click(&database.LinkMap{}, destination{})
+
+		if click.OS != c.os || click.DeviceType != c.deviceType {
+			t.Errorf("%s: got os %q and device type %q; want %q and %q", c.name, click.OS, click.DeviceType, c.os, c.deviceType)
+		}
+	}
+}
+
+func TestVisitClickBot(t *testing.T) {
+	userAgent := "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"
+	v := &visit{userAgent: userAgent, ua: useragent.Parse(userAgent), bot: true}
+
+	click := v.click(&database.LinkMap{}, destination{})
+	if !click.IsBot || click.DeviceType != "bot" {
+		t.Errorf("got is_bot %v and device type %q; want a bot", click.IsBot, click.DeviceType)
+	}
+}
+
+func TestVisitClickLocation(t *testing.T) {
+	v := &visit{}
+	if click := v.click(&database.LinkMap{}, destination{}); click.City != "" || click.Latitude != nil {
+		t.Errorf("got a location without the City database: %+v", click)
+	}
+
+	// The record types have no tags of their own, json fills them by field name
+	var city geoip2.City
+	record := `{
+		"City": {"Names": {"en": "Munich"}},
+		"Subdivisions": [{"Names": {"en": "Bavaria"}, "IsoCode": "BY"}],
+		"Location": {"Latitude": 48.1371, "Longitude": 11.5754}
+	}`
+	if err := json.Unmarshal([]byte(record), &city); err != nil {
+		t.Fatal(err)
+	}
+
+	v.city = &city
+	click := v.click(&database.LinkMap{}, destination{})
+
+	if click.City != "Munich" || click.Subdivision != "Bavaria" || click.SubdivisionISOCode != "BY" {
+		t.Errorf("got city %q in %q (%s)", click.City, click.Subdivision, click.SubdivisionISOCode)
+	}
+	if click.Latitude == nil || *click.Latitude != 48.1 || click.Longitude == nil || *click.Longitude != 11.6 {
+		t.Errorf("coordinates are not rounded to one decimal: %v, %v", click.Latitude, click.Longitude)
+	}
+}
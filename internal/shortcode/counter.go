@@ -0,0 +1,153 @@
+package shortcode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/scythe504/tiny-rl/internal"
+)
+
+const (
+	feistelRounds = 4
+	// maxBlockSize keeps every block and the Feistel domain within uint64
+	maxBlockSize = 1 << 62
+)
+
+// Counter turns the numbers of a sequence into codes so that no two numbers
+// ever give the same code.
+//
+// Numbers are split into blocks, one per code length starting at minLength,
+// and every number is encoded with the length of its block. Codes therefore
+// grow by themselves once all codes of a length are used up. With a key the
+// numbers of a block are shuffled by a Feistel network first, consecutive
+// links then no longer get guessable consecutive codes. The key must never
+// change once codes were handed out.
+type Counter struct {
+	counters
+
+	next      func() (uint64, error)
+	alphabet  string
+	minLength int
+	key       []byte
+	length    int
+}
+
+// NewCounter returns a generator for the numbers returned by next, typically
+// a database sequence. Without key the codes are plain base-N numbers.
+func NewCounter(next func() (uint64, error), alphabet string, minLength int, key []byte) (*Counter, error) {
+	if err := ValidateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if minLength < 1 {
+		minLength = 1
+	}
+
+	return &Counter{
+		next:      next,
+		alphabet:  alphabet,
+		minLength: minLength,
+		key:       key,
+		length:    minLength,
+	}, nil
+}
+
+func (g *Counter) Next() (string, error) {
+	var code string
+	// Reserved codes are skipped, their numbers are simply left unused
+	for code == "" || internal.ReservedAlias(code) {
+		n, err := g.next()
+		if err != nil {
+			return "", err
+		}
+		code = g.encode(n)
+	}
+
+	g.mu.Lock()
+	g.generated++
+	g.length = len(code)
+	g.mu.Unlock()
+
+	return code, nil
+}
+
+// Collided can only happen when a custom alias or a code from another
+// strategy already took the code, the next number is simply used instead.
+func (g *Counter) Collided() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.collisions++
+}
+
+func (g *Counter) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.stats(g.length)
+}
+
+func (g *Counter) encode(n uint64) string {
+	length := g.minLength
+	for {
+		size, halfBits := g.block(length)
+		if n < size {
+			if g.key != nil {
+				n = g.shuffle(n, halfBits)
+			}
+			break
+		}
+		n -= size
+		length++
+	}
+
+	base := uint64(len(g.alphabet))
+	code := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		code[i] = g.alphabet[n%base]
+		n /= base
+	}
+
+	return string(code)
+}
+
+// block returns the amount of numbers encoded with length characters. A
+// shuffled block is the largest 2^(2*halfBits) that fits, as the Feistel
+// network works on two halves of equal size.
+func (g *Counter) block(length int) (size uint64, halfBits uint) {
+	size = 1
+	base := uint64(len(g.alphabet))
+	for i := 0; i < length && size <= maxBlockSize/base; i++ {
+		size *= base
+	}
+
+	if g.key == nil {
+		return size, 0
+	}
+
+	halfBits = uint(bits.Len64(size)-1) / 2
+	return 1 << (2 * halfBits), halfBits
+}
+
+// shuffle is a bijection on [0, 2^(2*halfBits)).
+func (g *Counter) shuffle(n uint64, halfBits uint) uint64 {
+	mask := uint64(1)<<halfBits - 1
+	left, right := n>>halfBits, n&mask
+
+	for round := 0; round < feistelRounds; round++ {
+		left, right = right, left^(g.round(round, right)&mask)
+	}
+
+	return left<<halfBits | right
+}
+
+func (g *Counter) round(round int, value uint64) uint64 {
+	var buf [9]byte
+	buf[0] = byte(round)
+	binary.BigEndian.PutUint64(buf[1:], value)
+
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write(buf[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
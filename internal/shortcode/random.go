@@ -0,0 +1,109 @@
+package shortcode
+
+import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/scythe504/tiny-rl/internal"
+)
+
+const (
+	// growthWindow is the number of codes the collision rate of a random
+	// generator is measured over before deciding to grow.
+	growthWindow = 200
+)
+
+// Random generates codes of uniformly random characters. When the share of
+// taken codes within the last growthWindow codes goes over maxCollisionRate
+// the keyspace is considered crowded and codes get one character longer.
+type Random struct {
+	counters
+
+	alphabet         string
+	length           int
+	maxLength        int
+	maxCollisionRate float64
+
+	windowGenerated  int
+	windowCollisions int
+}
+
+// NewRandom returns a generator of length characters from alphabet. A
+// maxCollisionRate of 0 keeps the length fixed.
+func NewRandom(alphabet string, length int, maxLength int, maxCollisionRate float64) (*Random, error) {
+	if err := ValidateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if maxLength < length {
+		maxLength = length
+	}
+
+	return &Random{
+		alphabet:         alphabet,
+		length:           length,
+		maxLength:        maxLength,
+		maxCollisionRate: maxCollisionRate,
+	}, nil
+}
+
+func (g *Random) Next() (string, error) {
+	g.mu.Lock()
+	g.grow()
+	length := g.length
+	g.generated++
+	g.windowGenerated++
+	g.mu.Unlock()
+
+	for {
+		code, err := g.draw(length)
+		if err != nil || !internal.ReservedAlias(code) {
+			return code, err
+		}
+	}
+}
+
+// draw returns length random characters of the alphabet.
+func (g *Random) draw(length int) (string, error) {
+	code := make([]byte, length)
+	max := big.NewInt(int64(len(g.alphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = g.alphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
+
+func (g *Random) Collided() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.collisions++
+	g.windowCollisions++
+}
+
+func (g *Random) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.grow()
+	return g.stats(g.length)
+}
+
+// grow checks the finished window. It runs on Stats and before handing out
+// new codes, the caller holds the lock.
+func (g *Random) grow() {
+	if g.windowGenerated < growthWindow {
+		return
+	}
+
+	rate := float64(g.windowCollisions) / float64(g.windowGenerated)
+	if g.maxCollisionRate > 0 && rate > g.maxCollisionRate && g.length < g.maxLength {
+		g.length++
+	}
+
+	g.windowGenerated, g.windowCollisions = 0, 0
+}
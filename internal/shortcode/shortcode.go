@@ -0,0 +1,106 @@
+// Package shortcode generates the codes of short links.
+//
+// Generators only hand out candidates, the database decides whether a code is
+// still free. Codes that are reserved paths of the router are never handed
+// out. Callers report every code that turned out to be taken through
+// Collided, which is what the collision statistics and the automatic growth
+// of random codes are based on.
+package shortcode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultAlphabet is every character allowed by the {shortCode} route.
+	DefaultAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_-"
+	// Base62Alphabet leaves out '_' and '-' which are easy to miss in print.
+	Base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	// lookalikes are characters that are easily confused with each other
+	lookalikes = "0O1lI"
+)
+
+// Generator hands out candidate short codes. Implementations are safe for
+// concurrent use.
+type Generator interface {
+	Next() (string, error)
+	// Collided reports that a code returned by Next was already taken.
+	Collided()
+	Stats() Stats
+}
+
+// Stats describe how often generated codes were already taken.
+type Stats struct {
+	// Length is the length of the codes generated right now
+	Length     int    `json:"length"`
+	Generated  uint64 `json:"generated"`
+	Collisions uint64 `json:"collisions"`
+}
+
+// CollisionRate is the share of generated codes that were already taken.
+func (s Stats) CollisionRate() float64 {
+	if s.Generated == 0 {
+		return 0
+	}
+
+	return float64(s.Collisions) / float64(s.Generated)
+}
+
+// Entropy is the number of bits of a random code of length characters from
+// alphabet.
+func Entropy(alphabet string, length int) float64 {
+	return float64(length) * math.Log2(float64(len(alphabet)))
+}
+
+// ExcludeLookalikes removes 0/O and 1/l/I from an alphabet.
+func ExcludeLookalikes(alphabet string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(lookalikes, r) {
+			return -1
+		}
+		return r
+	}, alphabet)
+}
+
+// ValidateAlphabet checks that every code built from alphabet can be served
+// by the {shortCode} route and that no character repeats.
+func ValidateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return errors.New("alphabet needs at least 2 characters")
+	}
+
+	seen := make(map[byte]bool, len(alphabet))
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		valid := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+		if !valid {
+			return fmt.Errorf("alphabet character %q is not allowed in short codes", c)
+		}
+		if seen[c] {
+			return fmt.Errorf("alphabet character %q is repeated", c)
+		}
+		seen[c] = true
+	}
+
+	return nil
+}
+
+// counters keeps the totals shared by all generators.
+type counters struct {
+	mu         sync.Mutex
+	generated  uint64
+	collisions uint64
+}
+
+func (c *counters) stats(length int) Stats {
+	return Stats{
+		Length:     length,
+		Generated:  c.generated,
+		Collisions: c.collisions,
+	}
+}
@@ -0,0 +1,148 @@
+package shortcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExcludeLookalikes(t *testing.T) {
+	alphabet := ExcludeLookalikes(DefaultAlphabet)
+
+	for _, c := range lookalikes {
+		if strings.ContainsRune(alphabet, c) {
+			t.Errorf("expected %q to be removed", c)
+		}
+	}
+	if len(alphabet) != len(DefaultAlphabet)-len(lookalikes) {
+		t.Errorf("expected only lookalikes to be removed; got %q", alphabet)
+	}
+}
+
+func TestValidateAlphabet(t *testing.T) {
+	for _, alphabet := range []string{"", "a", "abca", "ab/c", "abé"} {
+		if err := ValidateAlphabet(alphabet); err == nil {
+			t.Errorf("expected alphabet %q to be rejected", alphabet)
+		}
+	}
+}
+
+func TestRandomGrowsWhenCrowded(t *testing.T) {
+	g, err := NewRandom("ab", 4, 6, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range growthWindow {
+		code, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 4 || strings.Trim(code, "ab") != "" {
+			t.Fatalf("unexpected code %q", code)
+		}
+		g.Collided()
+	}
+
+	stats := g.Stats()
+	if stats.Length != 5 {
+		t.Errorf("expected length to grow to 5; got %d", stats.Length)
+	}
+	if stats.CollisionRate() != 1 {
+		t.Errorf("expected collision rate 1; got %v", stats.CollisionRate())
+	}
+
+	// A quiet window keeps the length
+	for range growthWindow {
+		g.Next()
+	}
+	if length := g.Stats().Length; length != 5 {
+		t.Errorf("expected length to stay 5; got %d", length)
+	}
+}
+
+func TestCounterIsCollisionFree(t *testing.T) {
+	for _, key := range [][]byte{nil, []byte("secret")} {
+		var n uint64
+		g, err := NewCounter(func() (uint64, error) {
+			n++
+			return n - 1, nil
+		}, "abc", 2, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen := make(map[string]bool)
+		for range 5000 {
+			code, err := g.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seen[code] {
+				t.Fatalf("code %q was generated twice (key %q)", code, key)
+			}
+			seen[code] = true
+		}
+
+		if g.Stats().Length <= 2 {
+			t.Errorf("expected codes to grow past the minimum length (key %q)", key)
+		}
+	}
+}
+
+func TestCounterEncoding(t *testing.T) {
+	var n uint64
+	g, _ := NewCounter(func() (uint64, error) {
+		n++
+		return n - 1, nil
+	}, Base62Alphabet, 2, nil)
+
+	expected := []string{"00", "01", "02"}
+	for _, want := range expected {
+		if code, _ := g.Next(); code != want {
+			t.Errorf("expected %q; got %q", want, code)
+		}
+	}
+
+	// The first code after all two character codes
+	n = 62 * 62
+	if code, _ := g.Next(); code != "000" {
+		t.Errorf("expected %q; got %q", "000", code)
+	}
+}
+
+func TestGeneratorsSkipReservedCodes(t *testing.T) {
+	random, err := NewRandom("aip", 3, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n uint64
+	counter, err := NewCounter(func() (uint64, error) {
+		n++
+		return n - 1, nil
+	}, "aip", 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, g := range []Generator{random, counter} {
+		for range 200 {
+			code, err := g.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code == "api" {
+				t.Fatalf("reserved code %q was generated", code)
+			}
+		}
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	if bits := Entropy(Base62Alphabet, 22); bits < 128 || bits > 131 {
+		t.Errorf("expected 22 base62 characters to be about 130 bits; got %v", bits)
+	}
+	if bits := Entropy("ab", 22); bits != 22 {
+		t.Errorf("expected 22 bits; got %v", bits)
+	}
+}
@@ -0,0 +1,137 @@
+package urlpolicy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Lists are the domain allow and deny lists. An entry matches the domain
+// itself and all of its subdomains. When the allow list is empty every domain
+// that is not denied passes, otherwise only allowed domains do. Deny entries
+// always win, so a subdomain of an allowed domain can still be blocked.
+type Lists struct {
+	Allow []string
+	Deny  []string
+}
+
+// Allowed reports whether host passes the allow list.
+func (l *Lists) Allowed(host string) bool {
+	return len(l.Allow) == 0 || matchesAny(host, l.Allow)
+}
+
+// Denied reports whether host is on the deny list.
+func (l *Lists) Denied(host string) bool {
+	return matchesAny(host, l.Deny)
+}
+
+func matchesAny(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseLists reads one "allow <domain>" or "deny <domain>" entry per line.
+// Blank lines and everything after a '#' are ignored, a leading "*." on a
+// domain is optional since subdomains always match.
+func ParseLists(reader io.Reader) (*Lists, error) {
+	lists := &Lists{}
+
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"allow <domain>\" or \"deny <domain>\"", line)
+		}
+
+		domain := strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(fields[1]), "*."), ".")
+		if domain == "" || strings.ContainsAny(domain, "/:*") {
+			return nil, fmt.Errorf("line %d: invalid domain %q", line, fields[1])
+		}
+
+		switch strings.ToLower(fields[0]) {
+		case "allow":
+			lists.Allow = append(lists.Allow, domain)
+		case "deny":
+			lists.Deny = append(lists.Deny, domain)
+		default:
+			return nil, fmt.Errorf("line %d: unknown action %q", line, fields[0])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// LoadLists parses the lists file at path.
+func LoadLists(path string) (*Lists, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lists, err := ParseLists(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return lists, nil
+}
+
+// Watch reloads the lists file whenever its modification time or size
+// changes, checking every interval until ctx is done. A file that fails to
+// parse is logged and the lists loaded before stay in place.
+func (p *Policy) Watch(ctx context.Context, interval time.Duration) {
+	if p.listsFile == "" {
+		return
+	}
+
+	lastMod, lastSize := p.listsMod, p.listsSize
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(p.listsFile)
+		if err != nil {
+			log.Println("[URLPolicy] error while checking lists file", err)
+			continue
+		}
+		if info.ModTime().Equal(lastMod) && info.Size() == lastSize {
+			continue
+		}
+		lastMod, lastSize = info.ModTime(), info.Size()
+
+		lists, err := LoadLists(p.listsFile)
+		if err != nil {
+			log.Println("[URLPolicy] keeping previous lists, error while reloading", err)
+			continue
+		}
+
+		p.SetLists(lists)
+		log.Printf("[URLPolicy] reloaded %s: %d allowed, %d denied domains\n", p.listsFile, len(lists.Allow), len(lists.Deny))
+	}
+}
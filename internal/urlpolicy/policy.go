@@ -0,0 +1,234 @@
+// Package urlpolicy decides which destination urls may be shortened.
+//
+// A Policy checks the shape of a url (scheme, port, length), the hostname
+// against the local names and the domain allow and deny lists and, when a
+// Resolver is set, the addresses the hostname resolves to. Every rejection
+// comes with a Reason code that clients can act on.
+package urlpolicy
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Reason tells why a url was rejected.
+type Reason string
+
+const (
+	ReasonInvalidURL        Reason = "invalid_url"
+	ReasonUnsupportedScheme Reason = "unsupported_scheme"
+	ReasonURLTooLong        Reason = "url_too_long"
+	ReasonPortNotAllowed    Reason = "port_not_allowed"
+	ReasonIPAddress         Reason = "ip_address_not_allowed"
+	ReasonLocalHost         Reason = "local_host"
+	ReasonDomainDenied      Reason = "domain_denied"
+	ReasonDomainNotListed   Reason = "domain_not_listed"
+	ReasonPrivateAddress    Reason = "private_address"
+	ReasonUnresolvableHost  Reason = "unresolvable_host"
+	ReasonSelfReference     Reason = "self_reference"
+	ReasonTooManyHops       Reason = "too_many_hops"
+	ReasonShortenerFailed   Reason = "shortener_unresolved"
+)
+
+// DefaultMaxLength keeps urls well within what browsers and proxies accept.
+const DefaultMaxLength = 2048
+
+// resolveTimeout bounds the lookup of a single hostname
+const resolveTimeout = 3 * time.Second
+
+// Rejection is the error returned for a url that does not pass the policy.
+type Rejection struct {
+	Reason  Reason
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+func reject(reason Reason, format string, args ...any) *Rejection {
+	return &Rejection{Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// Resolver looks up the addresses of a hostname, *net.Resolver implements it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+type Options struct {
+	// MaxLength is the longest url accepted, DefaultMaxLength when 0
+	MaxLength int
+	// AllowedPorts may be used on top of the default port of the scheme
+	AllowedPorts []int
+	// Resolver is used to reject hostnames pointing at private or reserved
+	// addresses, nil skips the lookup
+	Resolver Resolver
+	// ListsFile holds the domain allow and deny lists, see ParseLists
+	ListsFile string
+	// OwnHost reports whether host serves our own short links, urls
+	// pointing back at us would redirect in a loop
+	OwnHost func(host string) (bool, error)
+}
+
+// Policy is safe for concurrent use, the lists can be swapped while urls are
+// being checked.
+type Policy struct {
+	maxLength int
+	ports     map[int]bool
+	resolver  Resolver
+	ownHost   func(host string) (bool, error)
+
+	listsFile string
+	lists     atomic.Pointer[Lists]
+	// listsMod and listsSize identify the version of the file last loaded
+	listsMod  time.Time
+	listsSize int64
+}
+
+// New builds a policy and loads the lists file, if any.
+func New(opts Options) (*Policy, error) {
+	p := &Policy{
+		maxLength: opts.MaxLength,
+		ports:     make(map[int]bool, len(opts.AllowedPorts)),
+		resolver:  opts.Resolver,
+		ownHost:   opts.OwnHost,
+		listsFile: opts.ListsFile,
+	}
+	if p.maxLength <= 0 {
+		p.maxLength = DefaultMaxLength
+	}
+
+	for _, port := range opts.AllowedPorts {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+		p.ports[port] = true
+	}
+
+	lists := &Lists{}
+	if p.listsFile != "" {
+		info, err := os.Stat(p.listsFile)
+		if err != nil {
+			return nil, err
+		}
+		p.listsMod, p.listsSize = info.ModTime(), info.Size()
+
+		if lists, err = LoadLists(p.listsFile); err != nil {
+			return nil, err
+		}
+	}
+	p.lists.Store(lists)
+
+	return p, nil
+}
+
+// SetLists replaces the domain lists.
+func (p *Policy) SetLists(lists *Lists) {
+	p.lists.Store(lists)
+}
+
+// Check returns a *Rejection when rawUrl may not be used as a destination,
+// other errors come from OwnHost.
+func (p *Policy) Check(ctx context.Context, rawUrl string) error {
+	if len(rawUrl) > p.maxLength {
+		return reject(ReasonURLTooLong, "url is longer than %d characters", p.maxLength)
+	}
+
+	uri, err := url.Parse(rawUrl)
+	if err != nil {
+		return reject(ReasonInvalidURL, "url could not be parsed")
+	}
+
+	scheme := strings.ToLower(uri.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return reject(ReasonUnsupportedScheme, "only http and https urls are supported")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(uri.Hostname()), ".")
+	if host == "" {
+		return reject(ReasonInvalidURL, "url has no hostname")
+	}
+
+	if port := uri.Port(); port != "" && !p.portAllowed(scheme, port) {
+		return reject(ReasonPortNotAllowed, "port %s is not allowed", port)
+	}
+
+	if _, err := netip.ParseAddr(host); err == nil {
+		return reject(ReasonIPAddress, "ip addresses are not allowed, use a hostname")
+	}
+
+	if isLocalHost(host) {
+		return reject(ReasonLocalHost, "local hostnames are not allowed")
+	}
+
+	if p.ownHost != nil {
+		own, err := p.ownHost(host)
+		if err != nil {
+			return err
+		}
+		if own {
+			return reject(ReasonSelfReference, "url points back at a short link of this service")
+		}
+	}
+
+	lists := p.lists.Load()
+	if lists.Denied(host) {
+		return reject(ReasonDomainDenied, "domain %s is blocked", host)
+	}
+	if !lists.Allowed(host) {
+		return reject(ReasonDomainNotListed, "domain %s is not on the allow list", host)
+	}
+
+	if p.resolver != nil {
+		return p.checkAddrs(ctx, host)
+	}
+
+	return nil
+}
+
+// portAllowed accepts the default port of the scheme and the allowed ports.
+func (p *Policy) portAllowed(scheme string, port string) bool {
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+
+	if (scheme == "http" && n == 80) || (scheme == "https" && n == 443) {
+		return true
+	}
+
+	return p.ports[n]
+}
+
+func (p *Policy) checkAddrs(ctx context.Context, host string) error {
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	addrs, err := p.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return reject(ReasonUnresolvableHost, "host %s could not be resolved", host)
+	}
+
+	// A single internal address is enough, the client may pick any of them
+	for _, addr := range addrs {
+		if IsReserved(addr) {
+			return reject(ReasonPrivateAddress, "host %s resolves to a private or reserved address", host)
+		}
+	}
+
+	return nil
+}
+
+// isLocalHost matches names that only make sense inside a network.
+func isLocalHost(host string) bool {
+	return host == "localhost" ||
+		strings.HasSuffix(host, ".localhost") ||
+		strings.HasSuffix(host, ".local")
+}
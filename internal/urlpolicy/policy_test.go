@@ -0,0 +1,227 @@
+package urlpolicy
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers from a fixed table, unknown hosts do not resolve
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	addrs, ok := f[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+
+	var result []netip.Addr
+	for _, addr := range addrs {
+		result = append(result, netip.MustParseAddr(addr))
+	}
+
+	return result, nil
+}
+
+func reason(err error) Reason {
+	var rejection *Rejection
+	if errors.As(err, &rejection) {
+		return rejection.Reason
+	}
+
+	return ""
+}
+
+func TestCheck(t *testing.T) {
+	policy, err := New(Options{
+		MaxLength:    64,
+		AllowedPorts: []int{8443},
+		Resolver: fakeResolver{
+			"example.com":        {"93.184.215.14"},
+			"shop.example.com":   {"2606:2800:21f:cb07:6820:80da:af6b:8b2c"},
+			"intranet.corp.com":  {"10.1.2.3"},
+			"mapped.example.org": {"::ffff:127.0.0.1"},
+			"blocked.example":    {"93.184.215.15"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.SetLists(&Lists{Deny: []string{"blocked.example"}})
+
+	cases := map[string]Reason{
+		"https://example.com/page":                       "",
+		"https://shop.example.com/cart":                  "",
+		"https://example.com:443/page":                   "",
+		"http://example.com:80/page":                     "",
+		"https://example.com:8443/page":                  "",
+		"http://example.com:443/page":                    ReasonPortNotAllowed,
+		"https://example.com:8080/page":                  ReasonPortNotAllowed,
+		"ftp://example.com/file":                         ReasonUnsupportedScheme,
+		"https:///path":                                  ReasonInvalidURL,
+		"https://93.184.215.14/":                         ReasonIPAddress,
+		"https://[::1]/":                                 ReasonIPAddress,
+		"http://localhost/":                              ReasonLocalHost,
+		"http://printer.local/":                          ReasonLocalHost,
+		"https://cdn.blocked.example/":                   ReasonDomainDenied,
+		"https://intranet.corp.com/":                     ReasonPrivateAddress,
+		"https://mapped.example.org/":                    ReasonPrivateAddress,
+		"https://unknown.example.net/":                   ReasonUnresolvableHost,
+		"https://example.com/" + strings.Repeat("a", 64): ReasonURLTooLong,
+	}
+
+	for rawUrl, want := range cases {
+		if got := reason(policy.Check(context.Background(), rawUrl)); got != want {
+			t.Errorf("Check(%q) = %q; want %q", rawUrl, got, want)
+		}
+	}
+}
+
+func TestAllowList(t *testing.T) {
+	policy, err := New(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.SetLists(&Lists{Allow: []string{"example.com"}, Deny: []string{"private.example.com"}})
+
+	cases := map[string]Reason{
+		"https://example.com/":                "",
+		"https://docs.example.com/":           "",
+		"https://notexample.com/":             ReasonDomainNotListed,
+		"https://private.example.com/":        ReasonDomainDenied,
+		"https://a.private.example.com/index": ReasonDomainDenied,
+	}
+
+	for rawUrl, want := range cases {
+		if got := reason(policy.Check(context.Background(), rawUrl)); got != want {
+			t.Errorf("Check(%q) = %q; want %q", rawUrl, got, want)
+		}
+	}
+}
+
+func TestSelfReference(t *testing.T) {
+	policy, err := New(Options{
+		OwnHost: func(host string) (bool, error) {
+			return host == "sho.rt" || host == "go.brand.com", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]Reason{
+		"https://sho.rt/abc":        ReasonSelfReference,
+		"https://Go.Brand.com./abc": ReasonSelfReference,
+		"https://brand.com/abc":     "",
+	}
+
+	for rawUrl, want := range cases {
+		if got := reason(policy.Check(context.Background(), rawUrl)); got != want {
+			t.Errorf("Check(%q) = %q; want %q", rawUrl, got, want)
+		}
+	}
+}
+
+func TestParseLists(t *testing.T) {
+	lists, err := ParseLists(strings.NewReader(`
+# partners
+allow example.com
+allow *.Example.org.   # trailing dot and wildcard are fine
+deny  ads.example.com
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(lists.Allow, ",") != "example.com,example.org" {
+		t.Errorf("unexpected allow list %v", lists.Allow)
+	}
+	if strings.Join(lists.Deny, ",") != "ads.example.com" {
+		t.Errorf("unexpected deny list %v", lists.Deny)
+	}
+
+	for _, invalid := range []string{"block example.com", "allow", "deny https://example.com"} {
+		if _, err := ParseLists(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestWatchReloadsLists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	if err := os.WriteFile(path, []byte("deny one.example\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := New(Options{ListsFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go policy.Watch(ctx, 10*time.Millisecond)
+
+	replaceFile(t, path, "deny one.example\ndeny two.example\n")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for reason(policy.Check(ctx, "https://two.example/")) != ReasonDomainDenied {
+		if time.Now().After(deadline) {
+			t.Fatal("lists file was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A broken file keeps the lists loaded before
+	replaceFile(t, path, "nonsense\n")
+	time.Sleep(50 * time.Millisecond)
+
+	if got := reason(policy.Check(ctx, "https://one.example/")); got != ReasonDomainDenied {
+		t.Errorf("expected previous lists to stay in place, got %q", got)
+	}
+}
+
+// replaceFile swaps the file in one step so the watcher never sees it half
+// written
+func replaceFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsReserved(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":         false,
+		"2606:4700::1111": false,
+		"127.0.0.1":       true,
+		"10.0.0.1":        true,
+		"172.20.1.1":      true,
+		"192.168.1.1":     true,
+		"100.64.0.1":      true,
+		"169.254.169.254": true,
+		"198.18.0.1":      true,
+		"0.0.0.0":         true,
+		"::1":             true,
+		"fd00::1":         true,
+		"fe80::1":         true,
+		"::ffff:10.0.0.1": true,
+		"2001:db8::1":     true,
+	}
+
+	for addr, want := range cases {
+		if got := IsReserved(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsReserved(%s) = %v; want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package urlpolicy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultShortenerHosts are well known link shorteners.
+var DefaultShortenerHosts = []string{
+	"bit.ly", "bitly.com", "buff.ly", "cutt.ly", "goo.gl", "is.gd",
+	"lnkd.in", "ow.ly", "rb.gy", "rebrand.ly", "shorturl.at", "t.co",
+	"t.ly", "tiny.cc", "tinyurl.com", "v.gd",
+}
+
+// DefaultMaxHops is enough for any sensible chain of shorteners.
+const DefaultMaxHops = 5
+
+const defaultResolveTimeout = 5 * time.Second
+
+// Unshortener follows urls of known shorteners to the url they redirect to.
+// Only shortener hosts are ever requested, the final target itself is not.
+type Unshortener struct {
+	client  *http.Client
+	hosts   map[string]bool
+	maxHops int
+}
+
+// NewUnshortener follows at most maxHops redirects between the given hosts.
+// Hosts are matched with and without their port, so a test server can be
+// listed as "127.0.0.1:port". client may be nil for a client with a short
+// timeout, its redirect policy is replaced.
+func NewUnshortener(hosts []string, maxHops int, client *http.Client) *Unshortener {
+	u := &Unshortener{
+		hosts:   make(map[string]bool, len(hosts)),
+		maxHops: maxHops,
+	}
+	if u.maxHops <= 0 {
+		u.maxHops = DefaultMaxHops
+	}
+
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			u.hosts[host] = true
+		}
+	}
+
+	var c http.Client
+	if client != nil {
+		c = *client
+	} else {
+		c.Timeout = defaultResolveTimeout
+	}
+	// Every redirect is a hop we want to look at ourselves
+	c.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	u.client = &c
+
+	return u
+}
+
+// IsShortener reports whether rawUrl points at one of the known shorteners.
+func (u *Unshortener) IsShortener(rawUrl string) bool {
+	uri, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+
+	return u.isShortener(uri)
+}
+
+func (u *Unshortener) isShortener(uri *url.URL) bool {
+	if uri.Scheme != "http" && uri.Scheme != "https" {
+		return false
+	}
+
+	return u.hosts[strings.ToLower(uri.Host)] || u.hosts[strings.ToLower(uri.Hostname())]
+}
+
+// Resolve follows rawUrl for as long as it points at a known shortener and
+// returns the first url that does not. A shortener answering without a
+// redirect ends the chain at its own url.
+func (u *Unshortener) Resolve(ctx context.Context, rawUrl string) (string, error) {
+	current, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", reject(ReasonInvalidURL, "url could not be parsed")
+	}
+
+	for hops := 0; u.isShortener(current); hops++ {
+		if hops == u.maxHops {
+			return "", reject(ReasonTooManyHops, "url redirects through more than %d shorteners", u.maxHops)
+		}
+
+		next, err := u.follow(ctx, current)
+		if err != nil {
+			return "", err
+		}
+		if next == nil {
+			break
+		}
+
+		current = next
+	}
+
+	return current.String(), nil
+}
+
+// follow requests uri and returns where it redirects to, nil when it does
+// not redirect.
+func (u *Unshortener) follow(ctx context.Context, uri *url.URL) (*url.URL, error) {
+	resp, err := u.request(ctx, http.MethodHead, uri)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = u.request(ctx, http.MethodGet, uri)
+	}
+	if err != nil {
+		return nil, reject(ReasonShortenerFailed, "%s could not be resolved", uri.Host)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		if resp.StatusCode >= 400 {
+			return nil, reject(ReasonShortenerFailed, "%s answered with status %d", uri.Host, resp.StatusCode)
+		}
+		return nil, nil
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return nil, reject(ReasonShortenerFailed, "%s redirected without a location", uri.Host)
+	}
+
+	return location, nil
+}
+
+func (u *Unshortener) request(ctx context.Context, method string, uri *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, uri.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the status and headers matter
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	return resp, nil
+}
@@ -0,0 +1,96 @@
+package urlpolicy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newShortener(t *testing.T) (*httptest.Server, *Unshortener) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/first", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/second", http.StatusFound)
+	})
+	mux.HandleFunc("/second", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com/landing?utm=x", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.Redirect(w, r, "https://example.com/get", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("an interstitial page"))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	return srv, NewUnshortener([]string{host}, 3, srv.Client())
+}
+
+func TestResolve(t *testing.T) {
+	srv, unshortener := newShortener(t)
+
+	cases := map[string]string{
+		srv.URL + "/first":            "https://example.com/landing?utm=x",
+		srv.URL + "/get-only":         "https://example.com/get",
+		srv.URL + "/page":             srv.URL + "/page",
+		"https://example.com/already": "https://example.com/already",
+	}
+
+	for rawUrl, want := range cases {
+		got, err := unshortener.Resolve(context.Background(), rawUrl)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %v", rawUrl, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Resolve(%q) = %q; want %q", rawUrl, got, want)
+		}
+	}
+}
+
+func TestResolveFailures(t *testing.T) {
+	srv, unshortener := newShortener(t)
+
+	cases := map[string]Reason{
+		srv.URL + "/loop":    ReasonTooManyHops,
+		srv.URL + "/missing": ReasonShortenerFailed,
+	}
+
+	for rawUrl, want := range cases {
+		_, err := unshortener.Resolve(context.Background(), rawUrl)
+		if got := reason(err); got != want {
+			t.Errorf("Resolve(%q) = %q; want %q", rawUrl, got, want)
+		}
+	}
+}
+
+func TestIsShortener(t *testing.T) {
+	unshortener := NewUnshortener(DefaultShortenerHosts, 0, nil)
+
+	cases := map[string]bool{
+		"https://bit.ly/abc":      true,
+		"http://T.CO/abc":         true,
+		"https://example.com/abc": false,
+		"https://notbit.ly/abc":   false,
+		"ftp://bit.ly/abc":        false,
+	}
+
+	for rawUrl, want := range cases {
+		if got := unshortener.IsShortener(rawUrl); got != want {
+			t.Errorf("IsShortener(%q) = %v; want %v", rawUrl, got, want)
+		}
+	}
+}
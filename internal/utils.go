@@ -1,63 +1,96 @@
 package internal
 
 import (
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
-	"math/big"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"time"
 )
 
-func ShortCode() string {
-	charset := "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ_-"
+// hostnamePattern matches lowercase DNS names with at least two labels.
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
 
-	var shortCode = ""
-	charsetLen := int64(len(charset))
-	// 6 length key (64^6)
-	for range 6 {
-		rd, _ := rand.Int(rand.Reader, big.NewInt(charsetLen))
-		shortCode += string(charset[int(rd.Int64())])
-	}
+// ValidHostname reports whether hostname can be used as a branded domain.
+func ValidHostname(hostname string) bool {
+	return len(hostname) <= 253 && hostnamePattern.MatchString(hostname)
+}
 
-	return shortCode
+// aliasPattern mirrors the {shortCode} route pattern so that every alias we
+// accept can actually be resolved by the router.
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// reservedAliases are top level paths already taken by the router.
+var reservedAliases = map[string]bool{
+	"api":    true,
+	"health": true,
 }
 
-func ValidURL(rawUrl string) bool {
-	uri, err := url.Parse(rawUrl)
-	if err != nil {
+const maxAliasLength = 64
+
+// ValidAlias reports whether a user supplied alias can be used as a short code.
+func ValidAlias(alias string) bool {
+	if alias == "" || len(alias) > maxAliasLength {
 		return false
 	}
 
-	if uri.Scheme != "http" && uri.Scheme != "https" {
+	if !aliasPattern.MatchString(alias) {
 		return false
 	}
 
-	port := uri.Port()
-
-	if port != "" &&
-		!(uri.Scheme != "http" && port != "80") &&
-		!(uri.Scheme != "https" && port != "443") {
+	if ReservedAlias(alias) {
 		return false
 	}
 
-	if uri.Hostname() == "" {
+	return true
+}
+
+// ReservedAlias reports whether code, in any case, is a top level path of
+// the router and can not be used as a short code.
+func ReservedAlias(code string) bool {
+	return reservedAliases[strings.ToLower(code)]
+}
+
+// deepLinkScheme matches RFC 3986 schemes like "myapp" or "itms-apps"
+var deepLinkScheme = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// blockedSchemes can run code or read local data in the browser
+var blockedSchemes = map[string]bool{
+	"javascript": true,
+	"vbscript":   true,
+	"data":       true,
+	"file":       true,
+	"blob":       true,
+	"about":      true,
+	"filesystem": true,
+}
+
+// ValidDeepLink reports whether rawUrl can be used as an app deep link such
+// as "myapp://product/42" or "intent://...". Web urls are not deep links,
+// they go through the destination policy instead. Deep links are only
+// accepted inside device rules, which always come with a web fallback.
+func ValidDeepLink(rawUrl string) bool {
+	uri, err := url.Parse(rawUrl)
+	if err != nil {
 		return false
 	}
 
-	if net.ParseIP(uri.Hostname()) != nil {
+	scheme := strings.ToLower(uri.Scheme)
+
+	if scheme == "http" || scheme == "https" {
 		return false
 	}
 
-	if strings.HasSuffix(uri.Hostname(), ".local") ||
-		uri.Hostname() == "localhost" {
+	if !deepLinkScheme.MatchString(scheme) || blockedSchemes[scheme] {
 		return false
 	}
 
-	return true
+	// Something has to follow the scheme, "myapp:" alone opens nothing
+	return uri.Opaque != "" || uri.Host != "" || uri.Path != ""
 }
 
 func GetClientIP(r *http.Request) string {
@@ -91,6 +124,84 @@ func GetClientIP(r *http.Request) string {
 
 	return ip
 }
+
+// ParseTrustedProxies parses a comma separated list of IPs and CIDR ranges
+// of the proxies in front of the server.
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address %q", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy range %q", entry)
+		}
+		proxies = append(proxies, network)
+	}
+
+	return proxies, nil
+}
+
+// RemoteIP returns the address of the client without trusting anything it
+// sent. X-Forwarded-For is only read when the connection comes from one of
+// the trusted proxies, and then from the right so that the first address
+// not added by a trusted proxy wins.
+func RemoteIP(r *http.Request, trusted []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !trustedProxy(ip, trusted) {
+		return ip
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !trustedProxy(hop, trusted) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func trustedProxy(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, network := range trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func getLocalIP() string {
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
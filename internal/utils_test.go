@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidAlias(t *testing.T) {
+	cases := map[string]bool{
+		"spring-sale":            true,
+		"Promo_2025":             true,
+		"":                       false,
+		"has space":              false,
+		"slash/alias":            false,
+		"health":                 false,
+		"API":                    false,
+		"emoji-🙂":                false,
+		string(make([]byte, 65)): false,
+	}
+
+	for alias, want := range cases {
+		if got := ValidAlias(alias); got != want {
+			t.Errorf("ValidAlias(%q) = %v; want %v", alias, got, want)
+		}
+	}
+}
+
+func TestValidDeepLink(t *testing.T) {
+	cases := map[string]bool{
+		"myapp://product/42":                     true,
+		"itms-apps://apps.apple.com/app/id1":     true,
+		"intent://scan/#Intent;scheme=zxing;end": true,
+		"https://example.com/app":                false,
+		"http://localhost/app":                   false,
+		"javascript:alert(1)":                    false,
+		"JavaScript://%0aalert(1)":               false,
+		"data:text/html,hi":                      false,
+		"myapp:":                                 false,
+		"not a url":                              false,
+	}
+
+	for rawUrl, want := range cases {
+		if got := ValidDeepLink(rawUrl); got != want {
+			t.Errorf("ValidDeepLink(%q) = %v; want %v", rawUrl, got, want)
+		}
+	}
+}
+
+func TestValidHostname(t *testing.T) {
+	cases := map[string]bool{
+		"go.example.com":      true,
+		"brand.io":            true,
+		"localhost":           false,
+		"Go.Example.com":      false,
+		"example.com:8080":    false,
+		"https://example.com": false,
+		"-bad.example.com":    false,
+		"example.com/path":    false,
+		"":                    false,
+	}
+
+	for hostname, want := range cases {
+		if got := ValidHostname(hostname); got != want {
+			t.Errorf("ValidHostname(%q) = %v; want %v", hostname, got, want)
+		}
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"203.0.113.7:4321", "", "203.0.113.7"},
+		// Untrusted clients can not pick their address
+		{"203.0.113.7:4321", "198.51.100.1", "203.0.113.7"},
+		{"10.1.2.3:80", "198.51.100.1", "198.51.100.1"},
+		// Addresses in front of the first untrusted hop are spoofable
+		{"10.1.2.3:80", "1.1.1.1, 198.51.100.1, 192.168.1.1", "198.51.100.1"},
+		{"10.1.2.3:80", "", "10.1.2.3"},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = c.remoteAddr
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		r.Header.Set("X-Real-IP", "1.2.3.4")
+
+		if got := RemoteIP(r, trusted); got != c.want {
+			t.Errorf("RemoteIP(%s, %q) = %s; want %s", c.remoteAddr, c.forwarded, got, c.want)
+		}
+	}
+
+	if _, err := ParseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("expected an invalid range to be rejected")
+	}
+}
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE link_map
+ADD COLUMN expires_at TIMESTAMP,
+ADD COLUMN max_clicks INTEGER CHECK (max_clicks > 0),
+ADD COLUMN click_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE link_map
+SET click_count = (
+  SELECT COUNT(*) FROM clicks WHERE clicks.short_code = link_map.short_code
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE link_map
+DROP COLUMN expires_at,
+DROP COLUMN max_clicks,
+DROP COLUMN click_count;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE accounts (
+  id SERIAL PRIMARY KEY,
+  name text NOT NULL,
+  created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE TABLE api_keys (
+  id SERIAL PRIMARY KEY,
+  account_id INTEGER NOT NULL REFERENCES accounts(id),
+  prefix VARCHAR(16) NOT NULL,
+  key_hash text NOT NULL UNIQUE,
+  created_at TIMESTAMP DEFAULT now(),
+  last_used_at TIMESTAMP,
+  revoked_at TIMESTAMP
+);
+
+ALTER TABLE link_map ADD COLUMN owner_id INTEGER REFERENCES accounts(id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE link_map DROP COLUMN owner_id;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS accounts;
+-- +goose StatementEnd
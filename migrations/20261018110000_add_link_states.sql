@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE link_map
+ADD COLUMN deleted_at TIMESTAMP,
+ADD COLUMN disabled_at TIMESTAMP;
+
+-- Clicks outlive their link so reports keep working after a link is removed
+ALTER TABLE clicks DROP CONSTRAINT clicks_short_code_fkey;
+CREATE INDEX clicks_short_code_idx ON clicks (short_code);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS clicks_short_code_idx;
+ALTER TABLE clicks ADD CONSTRAINT clicks_short_code_fkey 
+FOREIGN KEY (short_code) REFERENCES link_map(short_code);
+ALTER TABLE link_map
+DROP COLUMN deleted_at,
+DROP COLUMN disabled_at;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE link_revisions (
+  id SERIAL PRIMARY KEY,
+  short_code text NOT NULL REFERENCES link_map(short_code) ON DELETE CASCADE,
+  revision INTEGER NOT NULL,
+  url text NOT NULL,
+  created_at TIMESTAMP DEFAULT now(),
+  UNIQUE (short_code, revision)
+);
+
+ALTER TABLE link_map ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+
+INSERT INTO link_revisions (short_code, revision, url, created_at)
+SELECT short_code, 1, COALESCE(url, ''), COALESCE(updated_at, created_at, now()) FROM link_map;
+
+-- Clicks logged before revisions existed keep a NULL revision
+ALTER TABLE clicks ADD COLUMN revision INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE clicks DROP COLUMN revision;
+ALTER TABLE link_map DROP COLUMN revision;
+DROP TABLE IF EXISTS link_revisions;
+-- +goose StatementEnd
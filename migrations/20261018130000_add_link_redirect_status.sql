@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE link_map 
+ADD COLUMN redirect_status SMALLINT CHECK (redirect_status IN (301, 302, 307, 308));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE link_map DROP COLUMN redirect_status;
+-- +goose StatementEnd
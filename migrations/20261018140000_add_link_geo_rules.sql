@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE link_geo_rules (
+  id SERIAL PRIMARY KEY,
+  short_code text NOT NULL REFERENCES link_map(short_code) ON DELETE CASCADE,
+  country_iso_code VARCHAR(2) NOT NULL,
+  url text NOT NULL,
+  UNIQUE (short_code, country_iso_code)
+);
+
+ALTER TABLE clicks ADD COLUMN geo_rule_id INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE clicks DROP COLUMN geo_rule_id;
+DROP TABLE IF EXISTS link_geo_rules;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE link_device_rules (
+  id SERIAL PRIMARY KEY,
+  short_code text NOT NULL REFERENCES link_map(short_code) ON DELETE CASCADE,
+  position INTEGER NOT NULL,
+  os VARCHAR(16) NOT NULL DEFAULT 'any',
+  device VARCHAR(16) NOT NULL DEFAULT 'any',
+  url text NOT NULL,
+  fallback_url text NOT NULL,
+  UNIQUE (short_code, position)
+);
+
+ALTER TABLE clicks ADD COLUMN device_rule_id INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE clicks DROP COLUMN device_rule_id;
+DROP TABLE IF EXISTS link_device_rules;
+-- +goose StatementEnd
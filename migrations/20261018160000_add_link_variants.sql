@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE link_variants (
+  id SERIAL PRIMARY KEY,
+  short_code text NOT NULL REFERENCES link_map(short_code) ON DELETE CASCADE,
+  url text NOT NULL,
+  weight INTEGER NOT NULL CHECK (weight > 0)
+);
+
+CREATE INDEX link_variants_short_code_idx ON link_variants (short_code);
+
+ALTER TABLE clicks ADD COLUMN variant_id INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE clicks DROP COLUMN variant_id;
+DROP TABLE IF EXISTS link_variants;
+-- +goose StatementEnd
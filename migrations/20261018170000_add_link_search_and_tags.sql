@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE link_map
+ADD COLUMN title text,
+ADD COLUMN notes text;
+
+ALTER TABLE link_map
+ADD COLUMN dest_host text GENERATED ALWAYS AS (
+  lower(substring(url from '^[a-zA-Z][a-zA-Z0-9+.-]*://([^/:?#]+)'))
+) STORED,
+ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+  to_tsvector('simple', coalesce(url, '') || ' ' || coalesce(title, '') || ' ' || coalesce(notes, ''))
+) STORED;
+
+CREATE INDEX link_map_search_vector_idx ON link_map USING GIN (search_vector);
+CREATE INDEX link_map_owner_created_idx ON link_map (owner_id, created_at, short_code);
+CREATE INDEX link_map_owner_clicks_idx ON link_map (owner_id, click_count, short_code);
+CREATE INDEX link_map_dest_host_idx ON link_map (dest_host);
+
+CREATE TABLE tags (
+  id SERIAL PRIMARY KEY,
+  account_id INTEGER NOT NULL REFERENCES accounts(id),
+  name VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP DEFAULT now(),
+  UNIQUE (account_id, name)
+);
+
+CREATE TABLE link_tags (
+  short_code text NOT NULL REFERENCES link_map(short_code) ON DELETE CASCADE,
+  tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  PRIMARY KEY (short_code, tag_id)
+);
+
+CREATE INDEX link_tags_tag_id_idx ON link_tags (tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP TABLE IF EXISTS link_tags;
+DROP TABLE IF EXISTS tags;
+DROP INDEX IF EXISTS link_map_dest_host_idx;
+DROP INDEX IF EXISTS link_map_owner_clicks_idx;
+DROP INDEX IF EXISTS link_map_owner_created_idx;
+DROP INDEX IF EXISTS link_map_search_vector_idx;
+ALTER TABLE link_map
+DROP COLUMN search_vector,
+DROP COLUMN dest_host,
+DROP COLUMN title,
+DROP COLUMN notes;
+-- +goose StatementEnd
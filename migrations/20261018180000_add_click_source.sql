@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE clicks ADD COLUMN source text NOT NULL DEFAULT 'link';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE clicks DROP COLUMN source;
+-- +goose StatementEnd
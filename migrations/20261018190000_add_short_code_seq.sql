@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE SEQUENCE short_code_seq AS bigint MINVALUE 0 START 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP SEQUENCE IF EXISTS short_code_seq;
+-- +goose StatementEnd
@@ -0,0 +1,160 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TABLE domains (
+  id SERIAL PRIMARY KEY,
+  -- NULL for the default domain served under FRONTEND_URL
+  hostname text UNIQUE,
+  -- NULL when every account may use the domain
+  account_id INTEGER REFERENCES accounts(id),
+  created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE UNIQUE INDEX domains_default_idx ON domains ((hostname IS NULL)) WHERE hostname IS NULL;
+
+INSERT INTO domains (hostname) VALUES (NULL);
+
+-- Short codes are only unique per domain, links get a surrogate key that
+-- the other tables refer to instead
+ALTER TABLE link_map
+ADD COLUMN id BIGSERIAL,
+ADD COLUMN domain_id INTEGER REFERENCES domains(id);
+
+UPDATE link_map SET domain_id = (SELECT id FROM domains WHERE hostname IS NULL);
+
+ALTER TABLE link_map ALTER COLUMN domain_id SET NOT NULL;
+
+ALTER TABLE link_revisions ADD COLUMN link_id BIGINT;
+ALTER TABLE link_geo_rules ADD COLUMN link_id BIGINT;
+ALTER TABLE link_device_rules ADD COLUMN link_id BIGINT;
+ALTER TABLE link_variants ADD COLUMN link_id BIGINT;
+ALTER TABLE link_tags ADD COLUMN link_id BIGINT;
+ALTER TABLE clicks ADD COLUMN link_id BIGINT;
+
+UPDATE link_revisions t SET link_id = l.id FROM link_map l WHERE l.short_code = t.short_code;
+UPDATE link_geo_rules t SET link_id = l.id FROM link_map l WHERE l.short_code = t.short_code;
+UPDATE link_device_rules t SET link_id = l.id FROM link_map l WHERE l.short_code = t.short_code;
+UPDATE link_variants t SET link_id = l.id FROM link_map l WHERE l.short_code = t.short_code;
+UPDATE link_tags t SET link_id = l.id FROM link_map l WHERE l.short_code = t.short_code;
+UPDATE clicks t SET link_id = l.id FROM link_map l WHERE l.short_code = t.short_code;
+
+-- Dropping the columns also drops the foreign keys on link_map(short_code)
+-- and the constraints and indexes built on them
+ALTER TABLE link_revisions DROP COLUMN short_code;
+ALTER TABLE link_geo_rules DROP COLUMN short_code;
+ALTER TABLE link_device_rules DROP COLUMN short_code;
+ALTER TABLE link_variants DROP COLUMN short_code;
+ALTER TABLE link_tags DROP COLUMN short_code;
+ALTER TABLE clicks DROP COLUMN short_code;
+
+ALTER TABLE link_map DROP CONSTRAINT link_map_pkey;
+ALTER TABLE link_map ADD PRIMARY KEY (id);
+ALTER TABLE link_map ADD CONSTRAINT link_map_domain_short_code_key UNIQUE (domain_id, short_code);
+
+DROP INDEX IF EXISTS link_map_owner_created_idx;
+DROP INDEX IF EXISTS link_map_owner_clicks_idx;
+CREATE INDEX link_map_owner_created_idx ON link_map (owner_id, created_at, id);
+CREATE INDEX link_map_owner_clicks_idx ON link_map (owner_id, click_count, id);
+
+ALTER TABLE link_revisions
+ALTER COLUMN link_id SET NOT NULL,
+ADD FOREIGN KEY (link_id) REFERENCES link_map(id) ON DELETE CASCADE,
+ADD UNIQUE (link_id, revision);
+
+ALTER TABLE link_geo_rules
+ALTER COLUMN link_id SET NOT NULL,
+ADD FOREIGN KEY (link_id) REFERENCES link_map(id) ON DELETE CASCADE,
+ADD UNIQUE (link_id, country_iso_code);
+
+ALTER TABLE link_device_rules
+ALTER COLUMN link_id SET NOT NULL,
+ADD FOREIGN KEY (link_id) REFERENCES link_map(id) ON DELETE CASCADE,
+ADD UNIQUE (link_id, position);
+
+ALTER TABLE link_variants
+ALTER COLUMN link_id SET NOT NULL,
+ADD FOREIGN KEY (link_id) REFERENCES link_map(id) ON DELETE CASCADE;
+CREATE INDEX link_variants_link_id_idx ON link_variants (link_id);
+
+ALTER TABLE link_tags
+ALTER COLUMN link_id SET NOT NULL,
+ADD FOREIGN KEY (link_id) REFERENCES link_map(id) ON DELETE CASCADE,
+ADD PRIMARY KEY (link_id, tag_id);
+
+-- Like before clicks keep no foreign key so they outlive their link
+CREATE INDEX clicks_link_id_idx ON clicks (link_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- Only links of the default domain can be mapped back to a global code.
+-- Rather than dropping links on branded domains, and their clicks with
+-- them, the rollback refuses to run until they are moved or deleted.
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM link_map l JOIN domains d ON d.id = l.domain_id WHERE d.hostname IS NOT NULL) THEN
+    RAISE EXCEPTION 'links on branded domains exist, move or delete them before rolling back';
+  END IF;
+END
+$$;
+
+ALTER TABLE link_revisions ADD COLUMN short_code text;
+ALTER TABLE link_geo_rules ADD COLUMN short_code text;
+ALTER TABLE link_device_rules ADD COLUMN short_code text;
+ALTER TABLE link_variants ADD COLUMN short_code text;
+ALTER TABLE link_tags ADD COLUMN short_code text;
+ALTER TABLE clicks ADD COLUMN short_code text;
+
+UPDATE link_revisions t SET short_code = l.short_code FROM link_map l WHERE l.id = t.link_id;
+UPDATE link_geo_rules t SET short_code = l.short_code FROM link_map l WHERE l.id = t.link_id;
+UPDATE link_device_rules t SET short_code = l.short_code FROM link_map l WHERE l.id = t.link_id;
+UPDATE link_variants t SET short_code = l.short_code FROM link_map l WHERE l.id = t.link_id;
+UPDATE link_tags t SET short_code = l.short_code FROM link_map l WHERE l.id = t.link_id;
+UPDATE clicks t SET short_code = l.short_code FROM link_map l WHERE l.id = t.link_id;
+
+ALTER TABLE link_revisions DROP COLUMN link_id;
+ALTER TABLE link_geo_rules DROP COLUMN link_id;
+ALTER TABLE link_device_rules DROP COLUMN link_id;
+ALTER TABLE link_variants DROP COLUMN link_id;
+ALTER TABLE link_tags DROP COLUMN link_id;
+ALTER TABLE clicks DROP COLUMN link_id;
+
+DROP INDEX IF EXISTS link_map_owner_created_idx;
+DROP INDEX IF EXISTS link_map_owner_clicks_idx;
+ALTER TABLE link_map DROP CONSTRAINT link_map_domain_short_code_key;
+ALTER TABLE link_map DROP CONSTRAINT link_map_pkey;
+ALTER TABLE link_map ADD PRIMARY KEY (short_code);
+ALTER TABLE link_map DROP COLUMN domain_id, DROP COLUMN id;
+CREATE INDEX link_map_owner_created_idx ON link_map (owner_id, created_at, short_code);
+CREATE INDEX link_map_owner_clicks_idx ON link_map (owner_id, click_count, short_code);
+
+ALTER TABLE link_revisions
+ALTER COLUMN short_code SET NOT NULL,
+ADD FOREIGN KEY (short_code) REFERENCES link_map(short_code) ON DELETE CASCADE,
+ADD UNIQUE (short_code, revision);
+
+ALTER TABLE link_geo_rules
+ALTER COLUMN short_code SET NOT NULL,
+ADD FOREIGN KEY (short_code) REFERENCES link_map(short_code) ON DELETE CASCADE,
+ADD UNIQUE (short_code, country_iso_code);
+
+ALTER TABLE link_device_rules
+ALTER COLUMN short_code SET NOT NULL,
+ADD FOREIGN KEY (short_code) REFERENCES link_map(short_code) ON DELETE CASCADE,
+ADD UNIQUE (short_code, position);
+
+ALTER TABLE link_variants
+ALTER COLUMN short_code SET NOT NULL,
+ADD FOREIGN KEY (short_code) REFERENCES link_map(short_code) ON DELETE CASCADE;
+CREATE INDEX link_variants_short_code_idx ON link_variants (short_code);
+
+ALTER TABLE link_tags
+ALTER COLUMN short_code SET NOT NULL,
+ADD FOREIGN KEY (short_code) REFERENCES link_map(short_code) ON DELETE CASCADE,
+ADD PRIMARY KEY (short_code, tag_id);
+
+CREATE INDEX clicks_short_code_idx ON clicks (short_code);
+
+DROP TABLE IF EXISTS domains;
+-- +goose StatementEnd
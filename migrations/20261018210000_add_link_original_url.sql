@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- original_url is the url a link was created with when it pointed at another
+-- shortener, url then holds the final target it resolved to
+ALTER TABLE link_map ADD COLUMN original_url text;
+ALTER TABLE link_revisions ADD COLUMN original_url text;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE link_revisions DROP COLUMN original_url;
+ALTER TABLE link_map DROP COLUMN original_url;
+-- +goose StatementEnd
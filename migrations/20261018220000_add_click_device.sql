@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Clicks recorded before these columns existed show up as 'Other'
+ALTER TABLE clicks
+    ADD COLUMN browser_version text NOT NULL DEFAULT '',
+    ADD COLUMN os text NOT NULL DEFAULT 'Other',
+    ADD COLUMN os_version text NOT NULL DEFAULT '',
+    ADD COLUMN device text NOT NULL DEFAULT '',
+    ADD COLUMN device_type text NOT NULL DEFAULT 'other';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE clicks
+    DROP COLUMN device_type,
+    DROP COLUMN device,
+    DROP COLUMN os_version,
+    DROP COLUMN os,
+    DROP COLUMN browser_version;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE clicks ADD COLUMN is_bot boolean NOT NULL DEFAULT false;
+-- Older clicks are classified by user agent only, the ASN list was not
+-- known when they were recorded
+UPDATE clicks SET is_bot = true
+    WHERE device_type = 'bot'
+       OR user_agent = ''
+       OR user_agent ~* '(bot|crawl|spider|slurp|facebookexternalhit|whatsapp|skypeuripreview|embedly|uptimerobot|pingdom|statuscake|site24x7|betteruptime|headlesschrome|curl/|wget/|python-requests|go-http-client|okhttp|axios/|node-fetch)';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE clicks DROP COLUMN is_bot;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- Filled only when the GeoLite2-City database is installed
+ALTER TABLE clicks
+    ADD COLUMN subdivision text NOT NULL DEFAULT '',
+    ADD COLUMN subdivision_iso_code text NOT NULL DEFAULT '',
+    ADD COLUMN city text NOT NULL DEFAULT '',
+    ADD COLUMN latitude double precision,
+    ADD COLUMN longitude double precision;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE clicks
+    DROP COLUMN longitude,
+    DROP COLUMN latitude,
+    DROP COLUMN city,
+    DROP COLUMN subdivision_iso_code,
+    DROP COLUMN subdivision;
+-- +goose StatementEnd
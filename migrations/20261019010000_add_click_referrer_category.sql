@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE clicks
+    ADD COLUMN raw_referrer text NOT NULL DEFAULT '',
+    ADD COLUMN referrer_domain text NOT NULL DEFAULT '',
+    ADD COLUMN referrer_category text NOT NULL DEFAULT 'other';
+-- Older clicks keep their referrer as raw value, `admin clicks classify-referrers`
+-- normalizes them. Direct clicks need no rules.
+UPDATE clicks SET raw_referrer = referrer WHERE referrer <> 'direct';
+UPDATE clicks SET referrer_domain = 'direct', referrer_category = 'direct' WHERE referrer = 'direct';
+CREATE INDEX clicks_unclassified_referrer_idx ON clicks (raw_referrer) WHERE referrer_domain = '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+DROP INDEX IF EXISTS clicks_unclassified_referrer_idx;
+UPDATE clicks SET referrer = raw_referrer WHERE raw_referrer <> '';
+ALTER TABLE clicks
+    DROP COLUMN referrer_category,
+    DROP COLUMN referrer_domain,
+    DROP COLUMN raw_referrer;
+-- +goose StatementEnd
@@ -1,8 +1,6 @@
 #!/bin/bash
 set -e
 
-echo "Downloading GeoLite2-Country.mmdb ..."
-
 mkdir -p /app/data
 
 if [ -z "$MAXMIND_ACCOUNT_ID" ] || [ -z "$MAXMIND_LICENSE_KEY" ]; then
@@ -13,27 +11,42 @@ if [ -z "$MAXMIND_ACCOUNT_ID" ] || [ -z "$MAXMIND_LICENSE_KEY" ]; then
     exit 1
 fi
 
-echo "Downloading from MaxMind..."
-
 # Create a temporary directory for this operation
 TEMP_DIR=$(mktemp -d)
 trap 'rm -rf $TEMP_DIR' EXIT
 
-curl -L -u "${MAXMIND_ACCOUNT_ID}:${MAXMIND_LICENSE_KEY}" \
-    "https://download.maxmind.com/geoip/databases/GeoLite2-Country/download?suffix=tar.gz" \
-    -o "$TEMP_DIR/mmdb.tar.gz"
+# download_edition fetches a GeoLite2 edition (Country, City, ...) to
+# /app/data/GeoLite2-<edition>.mmdb
+download_edition() {
+    local edition="$1"
+    local dir="$TEMP_DIR/$edition"
+    mkdir -p "$dir"
 
-echo "Extracting archive..."
-tar -xzf "$TEMP_DIR/mmdb.tar.gz" -C "$TEMP_DIR"
+    echo "Downloading GeoLite2-$edition.mmdb from MaxMind..."
+    curl -L -u "${MAXMIND_ACCOUNT_ID}:${MAXMIND_LICENSE_KEY}" \
+        "https://download.maxmind.com/geoip/databases/GeoLite2-$edition/download?suffix=tar.gz" \
+        -o "$dir/mmdb.tar.gz"
 
-# Find and move the .mmdb file
-MMDB_FILE=$(find "$TEMP_DIR" -name "*.mmdb" -type f | head -n 1)
+    echo "Extracting archive..."
+    tar -xzf "$dir/mmdb.tar.gz" -C "$dir"
 
-if [ -z "$MMDB_FILE" ]; then
-    echo "Error: Could not find .mmdb file in downloaded archive"
-    exit 1
-fi
+    # Find and move the .mmdb file
+    MMDB_FILE=$(find "$dir" -name "*.mmdb" -type f | head -n 1)
 
-mv "$MMDB_FILE" /app/data/GeoLite2-Country.mmdb
+    if [ -z "$MMDB_FILE" ]; then
+        echo "Error: Could not find .mmdb file in downloaded archive"
+        exit 1
+    fi
 
-echo "GeoLite2-Country.mmdb downloaded successfully to /app/data/"
\ No newline at end of file
+    mv "$MMDB_FILE" "/app/data/GeoLite2-$edition.mmdb"
+
+    echo "GeoLite2-$edition.mmdb downloaded successfully to /app/data/"
+}
+
+download_edition Country
+
+# The City database is optional and a lot larger, it adds regions and cities
+# to click analytics
+if [ "$MAXMIND_DOWNLOAD_CITY" = "true" ]; then
+    download_edition City
+fi